@@ -1,10 +1,19 @@
 package cmd
 
 import (
+    "bufio"
     "fmt"
     "net"
+    "os"
+    "sort"
+    "strconv"
+    "strings"
+
     "github.com/fatih/color"
+    "github.com/spf13/cobra"
     terminal "github.com/wayneashleyberry/terminal-dimensions"
+
+    "github.com/Pablo0303/Alama/pkg/queuescanner"
 )
 
 // logReplace imprime el estado del escaneo en una sola línea.
@@ -33,3 +42,212 @@ func incrementIP(ip net.IP) net.IP {
     }
     return ip // Retorna la IP incrementada
 }
+
+// targetList son los objetivos de un escaneo. Los rangos se guardan como
+// prefijos y sus direcciones se generan al recorrerlos, para no tener en
+// memoria millones de cadenas con --provider-ranges.
+type targetList struct {
+    nets  []*net.IPNet
+    lines []string
+    ports []string // puertos por dirección de los rangos, si se indicaron
+}
+
+// maxRangeBits es el tamaño máximo, en bits de host, de un rango a escanear:
+// 32 (un /0 de IPv4) donde int es de 64 bits y 30 donde es de 32, para que el
+// número de direcciones quepa en un int.
+const maxRangeBits = 28 + strconv.IntSize/16
+
+// rangeSize devuelve el número de direcciones del rango.
+func rangeSize(ipnet *net.IPNet) int {
+    ones, bits := ipnet.Mask.Size()
+    return 1 << (bits - ones)
+}
+
+// Len devuelve el número de objetivos.
+func (t *targetList) Len() int {
+    n := 0
+    for _, ipnet := range t.nets {
+        n += rangeSize(ipnet)
+    }
+    if len(t.ports) > 0 {
+        n *= len(t.ports)
+    }
+    return n + len(t.lines)
+}
+
+// Each llama a fn con cada objetivo y su posición, en orden: primero las
+// direcciones de los rangos y después las líneas del archivo.
+func (t *targetList) Each(fn func(i int, target string)) {
+    i := 0
+    for _, ipnet := range t.nets {
+        ip := ipnet.IP.Mask(ipnet.Mask)
+        for n := rangeSize(ipnet); n > 0; n-- {
+            if len(t.ports) == 0 {
+                fn(i, ip.String())
+                i++
+            } else {
+                for _, addr := range targetAddrs(ip.String(), t.ports) {
+                    fn(i, addr)
+                    i++
+                }
+            }
+            incrementIP(ip)
+        }
+    }
+    for _, line := range t.lines {
+        fn(i, line)
+        i++
+    }
+}
+
+// WithPorts hace que cada objetivo sin puerto se pruebe en todos los de
+// ports (ver targetAddrs).
+func (t *targetList) WithPorts(ports []string) {
+    var lines []string
+    for _, line := range t.lines {
+        lines = append(lines, targetAddrs(line, ports)...)
+    }
+    t.lines = lines
+    t.ports = ports
+}
+
+// Resolve sustituye los dominios de las líneas por sus IPs (ver
+// resolveTargets). Los rangos ya son IPs.
+func (t *targetList) Resolve(threads int) {
+    t.lines = resolveTargets(t.lines, threads)
+}
+
+// loadTargets devuelve las IPs de un rango CIDR, las IPv4 de los rangos
+// publicados de los proveedores indicados (separados por comas) y las líneas
+// de un archivo.
+func loadTargets(cidr, filename, providerRanges string) (*targetList, error) {
+    targets := &targetList{}
+
+    if cidr != "" {
+        _, ipnet, err := net.ParseCIDR(cidr)
+        if err != nil {
+            return nil, fmt.Errorf("rango CIDR inválido: %w", err)
+        }
+        if ones, bits := ipnet.Mask.Size(); bits-ones > maxRangeBits {
+            return nil, fmt.Errorf("rango CIDR demasiado grande: %s (máximo /%d en IPv4 y /%d en IPv6)", cidr, 32-maxRangeBits, 128-maxRangeBits)
+        }
+        targets.nets = append(targets.nets, ipnet)
+    }
+
+    if providerRanges != "" {
+        nets, err := providerNets(providerRanges, true, false)
+        if err != nil {
+            return nil, err
+        }
+        targets.nets = append(targets.nets, nets...)
+    }
+
+    if filename != "" {
+        lines, err := readLines(filename)
+        if err != nil {
+            return nil, err
+        }
+        targets.lines = lines
+    }
+
+    return targets, nil
+}
+
+// readLines devuelve las líneas no vacías de un archivo.
+func readLines(filename string) ([]string, error) {
+    file, err := os.Open(filename)
+    if err != nil {
+        return nil, fmt.Errorf("error al abrir el archivo: %w", err)
+    }
+    defer file.Close()
+
+    var lines []string
+    scanner := bufio.NewScanner(file)
+    for scanner.Scan() {
+        line := strings.TrimSpace(scanner.Text())
+        if line == "" {
+            continue
+        }
+        lines = append(lines, line)
+    }
+    if err := scanner.Err(); err != nil {
+        return nil, fmt.Errorf("error al leer el archivo: %w", err)
+    }
+    return lines, nil
+}
+
+// queueTargets añade los objetivos a la cola del escáner a medida que los
+// hilos los piden.
+func queueTargets(queueScanner *queuescanner.QueueScanner, targets *targetList) {
+    queueScanner.AddFunc(targets.Len(), func(add func(*queuescanner.QueueScannerScanParams)) {
+        targets.Each(func(_ int, target string) {
+            add(&queuescanner.QueueScannerScanParams{
+                Name: target,
+                Data: target,
+            })
+        })
+    })
+}
+
+// addProviderRangesFlag registra --provider-ranges en el comando.
+func addProviderRangesFlag(cmd *cobra.Command, p *string) {
+    cmd.Flags().StringVar(p, "provider-ranges", "", "Escanea las IPv4 de los rangos publicados por estos proveedores (ej. cloudflare,fastly)")
+}
+
+// splitList separa una lista separada por comas y descarta los elementos
+// vacíos.
+func splitList(s string) []string {
+    var items []string
+    for _, item := range strings.Split(s, ",") {
+        if item = strings.TrimSpace(item); item != "" {
+            items = append(items, item)
+        }
+    }
+    return items
+}
+
+// targetAddrs devuelve los host:puerto a probar para el objetivo. Si el
+// objetivo no indica puerto se prueban todos los de ports.
+func targetAddrs(target string, ports []string) []string {
+    if _, _, err := net.SplitHostPort(target); err == nil {
+        return []string{target}
+    }
+
+    addrs := make([]string, 0, len(ports))
+    for _, port := range ports {
+        addrs = append(addrs, net.JoinHostPort(target, port))
+    }
+    return addrs
+}
+
+// writeResults guarda los resultados en el archivo de salida, uno por línea.
+func writeResults(filename string, results []string) {
+    if filename == "" {
+        return
+    }
+    err := os.WriteFile(filename, []byte(strings.Join(results, "\n")), 0644)
+    if err != nil {
+        fmt.Println("Error al escribir en el archivo de salida:", err)
+    }
+}
+
+// providerSummary da formato al recuento de hosts por proveedor de CDN, de
+// mayor a menor.
+func providerSummary(counts map[string]int) string {
+    providers := make([]string, 0, len(counts))
+    for provider := range counts {
+        providers = append(providers, provider)
+    }
+    sort.Slice(providers, func(i, j int) bool {
+        if counts[providers[i]] != counts[providers[j]] {
+            return counts[providers[i]] > counts[providers[j]]
+        }
+        return providers[i] < providers[j]
+    })
+
+    parts := make([]string, 0, len(providers))
+    for _, provider := range providers {
+        parts = append(parts, fmt.Sprintf("%s=%d", provider, counts[provider]))
+    }
+    return strings.Join(parts, " ")
+}
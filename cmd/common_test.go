@@ -0,0 +1,70 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func targetStrings(t *targetList) []string {
+	var targets []string
+	t.Each(func(i int, target string) {
+		if i != len(targets) {
+			panic("posición fuera de orden")
+		}
+		targets = append(targets, target)
+	})
+	return targets
+}
+
+func TestLoadTargets(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "hosts.txt")
+	if err := os.WriteFile(file, []byte("example.com\n\n  1.2.3.4:8080 \n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	targets, err := loadTargets("10.0.0.4/30", file, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"10.0.0.4", "10.0.0.5", "10.0.0.6", "10.0.0.7", "example.com", "1.2.3.4:8080"}
+	if got := targetStrings(targets); !reflect.DeepEqual(got, want) {
+		t.Errorf("objetivos = %v, se esperaba %v", got, want)
+	}
+	if targets.Len() != len(want) {
+		t.Errorf("Len = %d, se esperaba %d", targets.Len(), len(want))
+	}
+
+	targets.WithPorts([]string{"80", "443"})
+	want = []string{
+		"10.0.0.4:80", "10.0.0.4:443", "10.0.0.5:80", "10.0.0.5:443",
+		"10.0.0.6:80", "10.0.0.6:443", "10.0.0.7:80", "10.0.0.7:443",
+		"example.com:80", "example.com:443", "1.2.3.4:8080",
+	}
+	if got := targetStrings(targets); !reflect.DeepEqual(got, want) {
+		t.Errorf("objetivos con puertos = %v, se esperaba %v", got, want)
+	}
+	if targets.Len() != len(want) {
+		t.Errorf("Len con puertos = %d, se esperaba %d", targets.Len(), len(want))
+	}
+}
+
+func TestLoadTargetsLargeRanges(t *testing.T) {
+	// Los rangos no se expanden al cargarlos.
+	targets, err := loadTargets(fmt.Sprintf("0.0.0.0/%d", 32-maxRangeBits), "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if targets.Len() != 1<<maxRangeBits {
+		t.Errorf("Len = %d", targets.Len())
+	}
+
+	if _, err := loadTargets("2001:db8::/64", "", ""); err == nil {
+		t.Error("un /64 debería rechazarse")
+	}
+	if _, err := loadTargets("2001:db8::/120", "", ""); err != nil {
+		t.Error(err)
+	}
+}
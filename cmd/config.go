@@ -0,0 +1,441 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+
+	"github.com/Pablo0303/Alama/pkg/resolve"
+)
+
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Crea, muestra y valida el archivo de configuración",
+	Long: `El archivo de configuración (--config, por defecto $HOME/.Alama.yaml) da
+valores a las banderas de cualquier comando. Cada clave es el nombre largo de
+una bandera:
+
+  threads: 100          se aplica a todos los comandos con --threads
+  httping:
+    timeout: 5          solo a httping
+  scan:
+    sni:
+      timeout: 3        solo a scan sni
+
+Una sección se aplica al comando y a sus subcomandos; la más específica gana.
+En profiles se definen perfiles con el mismo formato, que se eligen con
+--profile (o con la clave profile) y tienen prioridad sobre el resto del
+archivo.
+
+Las variables de entorno ALAMA_<BANDERA> y ALAMA_<COMANDO>_<BANDERA> (p. ej.
+ALAMA_THREADS o ALAMA_SCAN_SNI_TIMEOUT) tienen prioridad sobre el archivo, y
+las banderas de la línea de comandos sobre todo lo demás.`,
+	// Los subcomandos no escanean: no hace falta preparar proxies ni DNS.
+	PersistentPreRun: func(*cobra.Command, []string) {},
+}
+
+var configInitCmd = &cobra.Command{
+	Use:   "init",
+	Short: "Crea un archivo de configuración de ejemplo",
+	Args:  cobra.NoArgs,
+	Run:   runConfigInit,
+}
+
+var configShowCmd = &cobra.Command{
+	Use:   "show [comando...]",
+	Short: "Muestra los valores efectivos de las banderas de un comando y su origen",
+	Example: `  Alama config show
+  Alama config show scan sni --profile fastly-hunt`,
+	Run: runConfigShow,
+}
+
+var configValidateCmd = &cobra.Command{
+	Use:   "validate",
+	Short: "Comprueba que las claves y los valores del archivo son válidos",
+	Args:  cobra.NoArgs,
+	Run:   runConfigValidate,
+}
+
+var (
+	globalFlagProfile string
+	configFlagForce   bool
+)
+
+// configEnvPrefix es el prefijo de las variables de entorno de configuración.
+const configEnvPrefix = "ALAMA"
+
+// configReserved son las claves de primer nivel que no son banderas.
+var configReserved = map[string]bool{"profile": true, "profiles": true}
+
+// configSkip son las banderas que no se toman de la configuración.
+var configSkip = map[string]bool{"config": true, "profile": true, "help": true}
+
+// configTemplate es el archivo que crea config init.
+const configTemplate = `# Configuración de Alama. Cada clave es el nombre largo de una bandera.
+# Las claves de primer nivel se aplican a todos los comandos que tengan la
+# bandera; las secciones con el nombre de un comando, solo a ese comando y
+# sus subcomandos. Las variables ALAMA_<COMANDO>_<BANDERA> y las banderas de
+# la línea de comandos tienen prioridad.
+
+# proxy: socks5h://127.0.0.1:1080
+# resolver: [1.1.1.1, tls://9.9.9.9]
+
+threads: 50
+
+httping:
+  timeout: 5
+  follow-redirects: 3
+
+scan:
+  sni:
+    timeout: 3
+
+# Perfil por defecto; --profile lo sustituye.
+# profile: fastly-hunt
+
+profiles:
+  fastly-hunt:
+    provider-ranges: fastly
+    provider: fastly
+    httping:
+      status: 2xx
+`
+
+func init() {
+	rootCmd.AddCommand(configCmd)
+	configCmd.AddCommand(configInitCmd, configShowCmd, configValidateCmd)
+
+	configInitCmd.Flags().BoolVar(&configFlagForce, "force", false, "Sobrescribe el archivo si ya existe")
+}
+
+// configSetting es el valor efectivo de una bandera y su origen.
+type configSetting struct {
+	Flag   string
+	Value  string
+	Source string
+}
+
+// configPath devuelve las secciones del comando: [scan sni] para scan sni.
+func configPath(cmd *cobra.Command) []string {
+	var path []string
+	for c := cmd; c.HasParent(); c = c.Parent() {
+		path = append([]string{c.Name()}, path...)
+	}
+	return path
+}
+
+// configKeys devuelve las claves de la bandera para el comando, de la más a
+// la menos específica: scan.sni.timeout, scan.timeout y timeout.
+func configKeys(cmd *cobra.Command, flag string) []string {
+	path := configPath(cmd)
+	keys := make([]string, 0, len(path)+1)
+	for i := len(path); i >= 0; i-- {
+		keys = append(keys, strings.Join(append(path[:i:i], flag), "."))
+	}
+	return keys
+}
+
+// configEnv devuelve la variable de entorno de una clave: ALAMA_SCAN_SNI_TIMEOUT.
+func configEnv(key string) string {
+	return configEnvPrefix + "_" + strings.ToUpper(strings.NewReplacer(".", "_", "-", "_").Replace(key))
+}
+
+// configString convierte un valor del archivo al formato de la bandera. Las
+// secciones no son valores.
+func configString(v interface{}) (string, bool) {
+	switch v := v.(type) {
+	case nil, map[string]interface{}:
+		return "", false
+	case []interface{}:
+		parts := make([]string, 0, len(v))
+		for _, item := range v {
+			parts = append(parts, fmt.Sprint(item))
+		}
+		return strings.Join(parts, ","), true
+	}
+	return fmt.Sprint(v), true
+}
+
+// configProfile devuelve el perfil elegido con --profile, ALAMA_PROFILE o la
+// clave profile.
+func configProfile() (string, error) {
+	profile := globalFlagProfile
+	if profile == "" {
+		profile = viper.GetString("profile")
+	}
+	profile = strings.ToLower(profile)
+	if profile == "" {
+		return "", nil
+	}
+	if _, ok := viper.Get("profiles." + profile).(map[string]interface{}); !ok {
+		return "", fmt.Errorf("perfil desconocido: %s", profile)
+	}
+	return profile, nil
+}
+
+// lookupConfig busca el valor de la bandera en el entorno, el perfil y el
+// archivo, por este orden.
+func lookupConfig(cmd *cobra.Command, flag, profile string) (value, source string, ok bool) {
+	keys := configKeys(cmd, flag)
+	for _, key := range keys {
+		if v, ok := os.LookupEnv(configEnv(key)); ok {
+			return v, configEnv(key), true
+		}
+	}
+	if profile != "" {
+		for _, key := range keys {
+			if v, ok := configString(viper.Get("profiles." + profile + "." + key)); ok {
+				return v, "perfil " + profile, true
+			}
+		}
+	}
+	for _, key := range keys {
+		if v, ok := configString(viper.Get(key)); ok {
+			return v, "archivo", true
+		}
+	}
+	return "", "", false
+}
+
+// applyConfig da a las banderas de cmd que no se indicaron en la línea de
+// comandos su valor de la configuración, y devuelve el valor efectivo de
+// todas.
+func applyConfig(cmd *cobra.Command) ([]configSetting, error) {
+	profile, err := configProfile()
+	if err != nil {
+		return nil, err
+	}
+
+	cmd.InheritedFlags() // incorpora las banderas persistentes a cmd.Flags()
+	var settings []configSetting
+	cmd.Flags().VisitAll(func(f *pflag.Flag) {
+		if configSkip[f.Name] || err != nil {
+			return
+		}
+		source := "defecto"
+		if f.Changed {
+			source = "línea de comandos"
+		} else if value, from, ok := lookupConfig(cmd, f.Name, profile); ok {
+			if setErr := cmd.Flags().Set(f.Name, value); setErr != nil {
+				err = fmt.Errorf("--%s (%s): %w", f.Name, from, setErr)
+				return
+			}
+			source = from
+		}
+		settings = append(settings, configSetting{f.Name, f.Value.String(), source})
+	})
+	return settings, err
+}
+
+// configFileOrDefault devuelve --config o $HOME/.Alama.yaml.
+func configFileOrDefault() (string, error) {
+	if cfgFile != "" {
+		return cfgFile, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".Alama.yaml"), nil
+}
+
+func runConfigInit(cmd *cobra.Command, args []string) {
+	filename, err := configFileOrDefault()
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	if _, err := os.Stat(filename); err == nil && !configFlagForce {
+		fmt.Printf("%s ya existe; usa --force para sobrescribirlo\n", filename)
+		os.Exit(1)
+	}
+	if err := os.WriteFile(filename, []byte(configTemplate), 0644); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	fmt.Println("Archivo de configuración creado:", filename)
+}
+
+func runConfigShow(cmd *cobra.Command, args []string) {
+	target, rest, err := rootCmd.Find(args)
+	if err != nil || len(rest) > 0 {
+		fmt.Println("Comando desconocido:", strings.Join(args, " "))
+		os.Exit(1)
+	}
+
+	settings, err := applyConfig(target)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	if file := viper.ConfigFileUsed(); file != "" {
+		fmt.Println("Archivo:", file)
+	}
+	if profile, _ := configProfile(); profile != "" {
+		fmt.Println("Perfil:", profile)
+	}
+	fmt.Printf("Banderas de %s:\n", target.CommandPath())
+
+	width := 0
+	for _, s := range settings {
+		width = max(width, len(s.Flag))
+	}
+	for _, s := range settings {
+		fmt.Printf("  %-*s  %s (%s)\n", width, s.Flag, s.Value, s.Source)
+	}
+}
+
+func runConfigValidate(cmd *cobra.Command, args []string) {
+	filename := viper.ConfigFileUsed()
+	if filename == "" {
+		fmt.Println("No hay archivo de configuración; créalo con Alama config init")
+		os.Exit(1)
+	}
+
+	var problems []string
+	validateSection(rootCmd, "", viper.AllSettings(), &problems)
+	if _, err := configProfile(); err != nil {
+		problems = append(problems, err.Error())
+	}
+
+	if len(problems) > 0 {
+		fmt.Printf("%s tiene %d errores:\n", filename, len(problems))
+		for _, problem := range problems {
+			fmt.Println("  " + problem)
+		}
+		os.Exit(1)
+	}
+	fmt.Println("Configuración válida:", filename)
+}
+
+// validateSection comprueba las claves de la sección de cmd: cada una debe
+// ser un subcomando o una bandera de cmd o de sus subcomandos, con un valor
+// que la bandera acepte.
+func validateSection(cmd *cobra.Command, prefix string, section map[string]interface{}, problems *[]string) {
+	keys := make([]string, 0, len(section))
+	for key := range section {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		value := section[key]
+		name := prefix + key
+
+		if prefix == "" && configReserved[key] {
+			if key == "profiles" {
+				profiles, ok := value.(map[string]interface{})
+				if !ok {
+					*problems = append(*problems, "profiles debe ser una lista de perfiles con nombre")
+					continue
+				}
+				for profile, v := range profiles {
+					if m, ok := v.(map[string]interface{}); ok {
+						validateSection(rootCmd, "profiles."+profile+".", m, problems)
+					}
+				}
+			}
+			continue
+		}
+
+		if sub := subcommand(cmd, key); sub != nil {
+			if m, ok := value.(map[string]interface{}); ok {
+				validateSection(sub, name+".", m, problems)
+				continue
+			}
+		}
+
+		flags := configFlags(cmd, key)
+		if len(flags) == 0 || configSkip[key] {
+			*problems = append(*problems, fmt.Sprintf("%s: no es una bandera ni un comando", name))
+			continue
+		}
+		s, ok := configString(value)
+		if !ok {
+			*problems = append(*problems, fmt.Sprintf("%s: se esperaba un valor", name))
+			continue
+		}
+		for _, f := range flags {
+			if err := checkFlagValue(f, s); err != nil {
+				*problems = append(*problems, fmt.Sprintf("%s: %v", name, err))
+				break
+			}
+		}
+	}
+}
+
+// subcommand devuelve el subcomando de cmd con ese nombre o alias.
+func subcommand(cmd *cobra.Command, name string) *cobra.Command {
+	for _, sub := range cmd.Commands() {
+		if sub.Name() == name || sub.HasAlias(name) {
+			return sub
+		}
+	}
+	return nil
+}
+
+// configFlags devuelve las banderas con ese nombre de cmd y sus subcomandos.
+func configFlags(cmd *cobra.Command, name string) []*pflag.Flag {
+	var flags []*pflag.Flag
+	cmd.InheritedFlags()
+	if f := cmd.Flags().Lookup(name); f != nil {
+		flags = append(flags, f)
+	}
+	for _, sub := range cmd.Commands() {
+		flags = append(flags, configFlags(sub, name)...)
+	}
+	return flags
+}
+
+// checkFlagValue comprueba que la bandera acepta el valor sin modificarla.
+func checkFlagValue(f *pflag.Flag, s string) error {
+	switch f.Name {
+	case "resolver":
+		for _, r := range splitList(s) {
+			if _, err := resolve.ParseUpstream(r); err != nil {
+				return err
+			}
+		}
+		return nil
+	case "proxy":
+		_, err := parseProxy(s)
+		return err
+	case "proxy-rotation":
+		switch s {
+		case rotationRoundRobin, rotationRandom, rotationLeastLatency:
+			return nil
+		}
+		return fmt.Errorf("rotación desconocida: %s", s)
+	}
+
+	var err error
+	switch f.Value.Type() {
+	case "int":
+		_, err = strconv.Atoi(s)
+	case "bool":
+		_, err = strconv.ParseBool(s)
+	case "float64":
+		_, err = strconv.ParseFloat(s, 64)
+	}
+	if err != nil {
+		return fmt.Errorf("valor inválido para --%s (%s): %q", f.Name, f.Value.Type(), s)
+	}
+	return nil
+}
+
+// isConfigNotFound indica que el archivo de configuración no existe, lo que
+// no es un error: config init lo crea.
+func isConfigNotFound(err error) bool {
+	var notFound viper.ConfigFileNotFoundError
+	return errors.As(err, &notFound) || errors.Is(err, fs.ErrNotExist)
+}
@@ -0,0 +1,167 @@
+package cmd
+
+import (
+	"embed"
+	"encoding/csv"
+	"fmt"
+	"io/fs"
+	"net"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+
+	"github.com/Pablo0303/Alama/pkg/resolve"
+)
+
+// webFiles es el panel web que sirve serve en /.
+//
+//go:embed web
+var webFiles embed.FS
+
+// Campos que el panel extrae de las líneas de resultados.
+var (
+	hitStatusRe   = regexp.MustCompile(`(?:^|[\s(])([1-5]\d\d)(?:[\s)]|$)`)
+	hitLatencyRe  = regexp.MustCompile(`\b\d+(?:\.\d+)?(?:µs|ms|s)\b`)
+	hitProviderRe = regexp.MustCompile(`\[([a-z][a-z0-9-]*)\]`)
+)
+
+// serveHit es un resultado de un escaneo con los campos que muestra la tabla
+// del panel. Los campos que la línea no incluye quedan vacíos.
+type serveHit struct {
+	Target   string  `json:"target"`
+	Status   int     `json:"status,omitempty"`
+	Latency  float64 `json:"latency_ms,omitempty"`
+	Provider string  `json:"provider,omitempty"`
+	Line     string  `json:"line"`
+}
+
+// isHitTarget indica si el primer campo de una línea es un objetivo: una IP,
+// un host:puerto o un dominio. Así se descartan los resúmenes.
+func isHitTarget(s string) bool {
+	if host, _, err := net.SplitHostPort(s); err == nil {
+		s = host
+	}
+	if net.ParseIP(s) != nil {
+		return true
+	}
+	return strings.Contains(s, ".") && resolve.IsDomain(s)
+}
+
+// parseHit extrae el objetivo, el estado HTTP, la latencia y el proveedor de
+// una línea de resultados.
+func parseHit(line string) (*serveHit, bool) {
+	fields := strings.Fields(line)
+	if len(fields) == 0 || !isHitTarget(fields[0]) {
+		return nil, false
+	}
+	hit := &serveHit{Target: fields[0], Line: line}
+	rest := strings.TrimPrefix(line, fields[0])
+
+	if m := hitStatusRe.FindStringSubmatch(rest); m != nil {
+		hit.Status, _ = strconv.Atoi(m[1])
+	}
+	if m := hitLatencyRe.FindString(rest); m != "" {
+		if d, err := time.ParseDuration(m); err == nil {
+			hit.Latency = float64(d.Microseconds()) / 1000
+		}
+	}
+	if m := hitProviderRe.FindStringSubmatch(rest); m != nil {
+		hit.Provider = m[1]
+	}
+	return hit, true
+}
+
+// handleHits devuelve los resultados del escaneo en JSON o, con
+// ?format=csv, en CSV.
+func (s *scanServer) handleHits(w http.ResponseWriter, r *http.Request) {
+	j := s.job(w, r)
+	if j == nil {
+		return
+	}
+	j.mu.Lock()
+	hits := append([]*serveHit(nil), j.hits...)
+	j.mu.Unlock()
+
+	if r.URL.Query().Get("format") != "csv" {
+		writeJSON(w, http.StatusOK, hits)
+		return
+	}
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", "alama-"+j.id+".csv"))
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"target", "status", "latency_ms", "provider", "line"})
+	for _, hit := range hits {
+		status, latency := "", ""
+		if hit.Status > 0 {
+			status = strconv.Itoa(hit.Status)
+		}
+		if hit.Latency > 0 {
+			latency = strconv.FormatFloat(hit.Latency, 'f', -1, 64)
+		}
+		cw.Write([]string{hit.Target, status, latency, hit.Provider, hit.Line})
+	}
+	cw.Flush()
+}
+
+// commandInfo describe un comando que se puede lanzar desde el panel.
+type commandInfo struct {
+	Command string     `json:"command"`
+	Short   string     `json:"short"`
+	Input   string     `json:"input,omitempty"` // bandera que recibe targets
+	Flags   []flagInfo `json:"flags"`
+}
+
+type flagInfo struct {
+	Name    string `json:"name"`
+	Type    string `json:"type"`
+	Default string `json:"default"`
+	Usage   string `json:"usage"`
+}
+
+// servedCommands devuelve los comandos que guardan resultados, como los que
+// acepta POST /api/jobs, con sus banderas propias.
+func servedCommands(cmd *cobra.Command) []commandInfo {
+	var commands []commandInfo
+	for _, sub := range cmd.Commands() {
+		switch sub.Name() {
+		case "run", "serve", "config", "help", "completion":
+			continue
+		}
+		if sub.Runnable() && sub.Flags().Lookup("output") != nil {
+			info := commandInfo{
+				Command: strings.TrimPrefix(sub.CommandPath(), rootCmd.Name()+" "),
+				Short:   sub.Short,
+				Input:   jobInputFlag(sub),
+			}
+			sub.LocalFlags().VisitAll(func(f *pflag.Flag) {
+				if f.Name == "help" || f.Name == info.Input || serveFileFlags[f.Name] {
+					return
+				}
+				info.Flags = append(info.Flags, flagInfo{f.Name, f.Value.Type(), f.DefValue, f.Usage})
+			})
+			commands = append(commands, info)
+		}
+		commands = append(commands, servedCommands(sub)...)
+	}
+	sort.Slice(commands, func(i, j int) bool { return commands[i].Command < commands[j].Command })
+	return commands
+}
+
+func (s *scanServer) handleCommands(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	commands := servedCommands(rootCmd)
+	s.mu.Unlock()
+	writeJSON(w, http.StatusOK, commands)
+}
+
+// webHandler sirve el panel embebido.
+func webHandler() http.Handler {
+	sub, _ := fs.Sub(webFiles, "web")
+	return http.FileServerFS(sub)
+}
@@ -0,0 +1,211 @@
+package cmd
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/Pablo0303/Alama/pkg/queuescanner"
+	"github.com/Pablo0303/Alama/pkg/resolve"
+)
+
+var enumCmd = &cobra.Command{
+	Use:   "enum",
+	Short: "Busca subdominios por fuerza bruta con una lista de palabras",
+	Long: `Prueba cada palabra de la lista como subdominio de los dominios indicados y
+resuelve los candidatos con los resolvedores configurados (--resolver).
+
+Antes de probar un dominio se resuelven varios nombres aleatorios bajo él para
+detectar DNS comodín (*.dominio); los candidatos que solo devuelven las IPs del
+comodín se descartan. Con --depth mayor que 1 se repite la búsqueda bajo cada
+subdominio encontrado.
+
+El archivo de salida tiene un subdominio por línea, listo para -f en scan sni
+o direct.`,
+	Run: runEnum,
+}
+
+var (
+	enumFlagDomains  []string
+	enumFlagWordlist string
+	enumFlagOutput   string
+	enumFlagDepth    int
+	enumFlagTimeout  int
+	enumFlagThreads  int
+)
+
+// wildcardProbes es el número de nombres aleatorios con los que se detecta
+// un comodín.
+const wildcardProbes = 3
+
+func init() {
+	rootCmd.AddCommand(enumCmd)
+
+	enumCmd.Flags().StringSliceVarP(&enumFlagDomains, "domain", "d", nil, "Dominio a enumerar (repetible o separados por comas)")
+	enumCmd.Flags().StringVarP(&enumFlagWordlist, "wordlist", "w", "", "Archivo con una palabra por línea")
+	enumCmd.Flags().StringVarP(&enumFlagOutput, "output", "o", "", "Archivo de salida para guardar los subdominios")
+	enumCmd.Flags().IntVar(&enumFlagDepth, "depth", 1, "Niveles de subdominios a enumerar")
+	enumCmd.Flags().IntVarP(&enumFlagTimeout, "timeout", "t", 5, "Tiempo de espera de cada resolución en segundos")
+	enumCmd.Flags().IntVarP(&enumFlagThreads, "threads", "T", 50, "Número de hilos concurrentes")
+}
+
+// wildcardSet guarda las respuestas comodín de cada dominio.
+type wildcardSet struct {
+	resolver *resolve.Resolver
+
+	mu      sync.Mutex
+	domains map[string]*wildcard
+}
+
+// wildcard son las respuestas de *.dominio; vacío si no hay comodín.
+type wildcard struct {
+	once  sync.Once
+	ips   map[string]bool
+	cname string
+}
+
+// get detecta, una sola vez por dominio, si domain tiene comodín.
+func (s *wildcardSet) get(domain string) *wildcard {
+	s.mu.Lock()
+	w, ok := s.domains[domain]
+	if !ok {
+		w = &wildcard{ips: make(map[string]bool)}
+		s.domains[domain] = w
+	}
+	s.mu.Unlock()
+
+	w.once.Do(func() {
+		for i := 0; i < wildcardProbes; i++ {
+			res := s.resolver.Resolve(context.Background(), randomLabel()+"."+domain)
+			if res.Status != resolve.OK {
+				continue
+			}
+			for _, ip := range res.IPs {
+				w.ips[ip.String()] = true
+			}
+			if res.CNAME != "" {
+				w.cname = res.CNAME
+			}
+		}
+	})
+	return w
+}
+
+// Active indica si el dominio tiene comodín.
+func (w *wildcard) Active() bool {
+	return len(w.ips) > 0
+}
+
+// Matches indica si la resolución coincide con la del comodín.
+func (w *wildcard) Matches(res *resolve.Result) bool {
+	if !w.Active() {
+		return false
+	}
+	if w.cname != "" && strings.EqualFold(res.CNAME, w.cname) {
+		return true
+	}
+	for _, ip := range res.IPs {
+		if !w.ips[ip.String()] {
+			return false
+		}
+	}
+	return true
+}
+
+// randomLabel devuelve una etiqueta que no debería existir.
+func randomLabel() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return "alama-" + hex.EncodeToString(b)
+}
+
+// enumCandidate es un subdominio a probar y el dominio del que cuelga.
+type enumCandidate struct {
+	name   string
+	parent string
+}
+
+func scanEnum(resolver *resolve.Resolver, wildcards *wildcardSet) queuescanner.QueueScannerScanFunc {
+	return func(c *queuescanner.Ctx, p *queuescanner.QueueScannerScanParams) {
+		candidate := p.Data.(*enumCandidate)
+
+		res := resolver.Resolve(context.Background(), candidate.name)
+		if res.Status != resolve.OK || wildcards.get(candidate.parent).Matches(res) {
+			c.ScanError(res, res.Err, nil)
+			return
+		}
+
+		c.ScanSuccess(res, func() {
+			c.Log(colorG1.Sprint(resolvedString(res)))
+		})
+	}
+}
+
+func runEnum(cmd *cobra.Command, args []string) {
+	domains := make([]string, 0, len(enumFlagDomains))
+	for _, domain := range enumFlagDomains {
+		domain = strings.ToLower(strings.Trim(strings.TrimSpace(domain), "."))
+		if domain != "" {
+			domains = append(domains, domain)
+		}
+	}
+	if len(domains) == 0 || enumFlagWordlist == "" {
+		fmt.Println("Indica el dominio con -d y la lista de palabras con -w")
+		os.Exit(1)
+	}
+
+	words, err := readLines(enumFlagWordlist)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	resolver := resolve.New(time.Duration(enumFlagTimeout) * time.Second)
+	wildcards := &wildcardSet{resolver: resolver, domains: make(map[string]*wildcard)}
+
+	var found []string
+	seen := make(map[string]bool)
+	parents := domains
+	for level := 1; level <= enumFlagDepth && len(parents) > 0; level++ {
+		for _, parent := range parents {
+			if w := wildcards.get(parent); w.Active() {
+				fmt.Fprintf(os.Stderr, "DNS comodín en *.%s: se descartan sus respuestas\n", parent)
+			}
+		}
+
+		queueScanner := queuescanner.NewQueueScanner(enumFlagThreads, scanEnum(resolver, wildcards))
+		for _, parent := range parents {
+			for _, word := range words {
+				name := strings.ToLower(strings.Trim(word, ".")) + "." + parent
+				queueScanner.Add(&queuescanner.QueueScannerScanParams{
+					Name: name,
+					Data: &enumCandidate{name: name, parent: parent},
+				})
+			}
+		}
+
+		var next []string
+		queueScanner.Start(func(c *queuescanner.Ctx) {
+			for _, r := range c.ScanSuccessList {
+				res := r.(*resolve.Result)
+				if seen[res.Domain] {
+					continue
+				}
+				seen[res.Domain] = true
+				found = append(found, res.Domain)
+				next = append(next, res.Domain)
+			}
+			fmt.Printf("\nNivel %d: %d subdominios\n", level, len(next))
+		})
+		parents = next
+	}
+
+	writeResults(enumFlagOutput, found)
+}
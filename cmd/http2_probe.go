@@ -0,0 +1,354 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/hpack"
+
+	"github.com/Pablo0303/Alama/pkg/cdn"
+)
+
+// Protocolos que puede negociar la sonda HTTP/2.
+const (
+	protoH2         = "h2"          // HTTP/2 sobre TLS negociado por ALPN
+	protoH2C        = "h2c"         // HTTP/2 en claro con conocimiento previo
+	protoH2CUpgrade = "h2c-upgrade" // HTTP/2 en claro tras Upgrade: h2c
+	protoHTTP11     = "http/1.1"
+)
+
+// h2Stream es el identificador del único stream que abre la sonda.
+const h2Stream = 1
+
+// h2Setting es un valor de SETTINGS enviado por el servidor.
+type h2Setting struct {
+	ID  http2.SettingID
+	Val uint32
+}
+
+func (s h2Setting) String() string {
+	return fmt.Sprintf("%s=%d", s.ID, s.Val)
+}
+
+// settingsString da formato a los SETTINGS del servidor.
+func (r *httpResult) settingsString() string {
+	settings := make([]string, 0, len(r.Settings))
+	for _, s := range r.Settings {
+		settings = append(settings, s.String())
+	}
+	return strings.Join(settings, " ")
+}
+
+// h2Addr devuelve la dirección host:puerto y la autoridad de la solicitud.
+func (p *httpProbe) h2Addr(target string) (string, string, error) {
+	u, err := url.Parse(p.URL(target))
+	if err != nil {
+		return "", "", err
+	}
+	port := u.Port()
+	if port == "" {
+		port = "80"
+		if u.Scheme == "https" {
+			port = "443"
+		}
+	}
+
+	authority := p.Host
+	if authority == "" {
+		authority = u.Host
+	}
+	return net.JoinHostPort(u.Hostname(), port), authority, nil
+}
+
+// dial abre una conexión TCP con addr a través del proxy de la sonda.
+func (p *httpProbe) dial(addr string) (net.Conn, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), p.Timeout)
+	defer cancel()
+	conn, err := p.Proxy.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	conn.SetDeadline(time.Now().Add(p.Timeout))
+	return conn, nil
+}
+
+// FetchH2 solicita el objetivo intentando HTTP/2: ALPN h2 con https y, en
+// claro, h2c con conocimiento previo y, si falla, Upgrade: h2c. Si el
+// servidor no acepta HTTP/2 la respuesta es la de HTTP/1.1. No sigue
+// redirecciones.
+func (p *httpProbe) FetchH2(target string) (*httpResult, error) {
+	picked := *p
+	picked.Proxy = p.Proxy.pick()
+	p = &picked
+
+	addr, authority, err := p.h2Addr(target)
+	if err != nil {
+		return nil, err
+	}
+
+	start := time.Now()
+	var res *httpResult
+	if p.Scheme == "https" {
+		res, err = p.fetchALPN(addr, authority)
+	} else {
+		res, err = p.fetchH2C(addr, authority)
+		if err != nil {
+			res, err = p.fetchUpgrade(addr, authority)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	res.Target = target
+	res.Latency = time.Since(start)
+	res.FinalURL, _ = url.Parse(p.URL(target))
+	res.Proxy = p.Proxy.Label()
+	res.Provider = cdn.Classify(cdn.Evidence{
+		Header:     res.Header,
+		CertIssuer: res.CertIssuer,
+		IP:         net.ParseIP(res.FinalURL.Hostname()),
+	})
+	return res, nil
+}
+
+// fetchALPN negocia h2 por ALPN. Si el servidor elige HTTP/1.1 la solicitud
+// se hace con HTTP/1.1 sobre la misma conexión.
+func (p *httpProbe) fetchALPN(addr, authority string) (*httpResult, error) {
+	conn, err := p.dial(addr)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	config, err := newTLSConfig(p.SNI, p.TLSVerify)
+	if err != nil {
+		return nil, err
+	}
+	config.NextProtos = []string{protoH2, protoHTTP11}
+	tlsConn := tls.Client(conn, config)
+	if err := tlsConn.Handshake(); err != nil {
+		return nil, err
+	}
+	state := tlsConn.ConnectionState()
+
+	var res *httpResult
+	if state.NegotiatedProtocol == protoH2 {
+		res, err = p.h2Exchange(tlsConn, tlsConn, "https", authority, true)
+		if res != nil {
+			res.Proto = protoH2
+		}
+	} else {
+		res, err = p.http11Exchange(tlsConn, bufio.NewReader(tlsConn), authority, nil)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(state.PeerCertificates) > 0 {
+		res.CertIssuer = state.PeerCertificates[0].Issuer.String()
+	}
+	return res, nil
+}
+
+// fetchH2C habla HTTP/2 en claro sin negociación previa.
+func (p *httpProbe) fetchH2C(addr, authority string) (*httpResult, error) {
+	conn, err := p.dial(addr)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	res, err := p.h2Exchange(conn, conn, "http", authority, true)
+	if err != nil {
+		return nil, err
+	}
+	res.Proto = protoH2C
+	return res, nil
+}
+
+// fetchUpgrade pide el cambio a h2c desde HTTP/1.1. Si el servidor no lo
+// acepta se devuelve su respuesta HTTP/1.1.
+func (p *httpProbe) fetchUpgrade(addr, authority string) (*httpResult, error) {
+	conn, err := p.dial(addr)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	// SETTINGS vacío: HTTP2-Settings lleva su carga útil en base64url.
+	upgrade := http.Header{
+		"Connection":     {"Upgrade, HTTP2-Settings"},
+		"Upgrade":        {"h2c"},
+		"Http2-Settings": {""},
+	}
+	br := bufio.NewReader(conn)
+	res, err := p.http11Exchange(conn, br, authority, upgrade)
+	if err != nil || res.StatusCode != http.StatusSwitchingProtocols {
+		return res, err
+	}
+
+	// Tras el 101 la respuesta llega por HTTP/2 en el stream 1.
+	res, err = p.h2Exchange(conn, br, "http", authority, false)
+	if err != nil {
+		return nil, err
+	}
+	res.Proto = protoH2CUpgrade
+	return res, nil
+}
+
+// http11Exchange envía la solicitud con HTTP/1.1 y lee la respuesta.
+func (p *httpProbe) http11Exchange(w io.Writer, r *bufio.Reader, authority string, extra http.Header) (*httpResult, error) {
+	req, err := http.NewRequest(p.verb(), "http://"+authority+"/", nil)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range extra {
+		req.Header[k] = v
+	}
+	if err := req.Write(w); err != nil {
+		return nil, err
+	}
+
+	resp, err := http.ReadResponse(r, req)
+	if err != nil {
+		return nil, err
+	}
+	res := &httpResult{
+		StatusCode:    resp.StatusCode,
+		Status:        resp.Status,
+		Header:        resp.Header,
+		ContentLength: resp.ContentLength,
+		Proto:         protoHTTP11,
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		if p.BodyLimit > 0 {
+			res.Body, _ = io.ReadAll(io.LimitReader(resp.Body, p.BodyLimit))
+		}
+		resp.Body.Close()
+	}
+	return res, nil
+}
+
+// verb devuelve el método de la solicitud.
+func (p *httpProbe) verb() string {
+	if p.Verb == "" {
+		return http.MethodGet
+	}
+	return p.Verb
+}
+
+// h2Exchange envía el prefacio y SETTINGS y, si send es true, la solicitud
+// en el stream 1. Lee los SETTINGS del servidor y la respuesta del stream 1.
+func (p *httpProbe) h2Exchange(w io.Writer, r io.Reader, scheme, authority string, send bool) (*httpResult, error) {
+	if _, err := io.WriteString(w, http2.ClientPreface); err != nil {
+		return nil, err
+	}
+	framer := http2.NewFramer(w, r)
+	framer.ReadMetaHeaders = hpack.NewDecoder(4096, nil)
+	if err := framer.WriteSettings(); err != nil {
+		return nil, err
+	}
+
+	if send {
+		var block bytes.Buffer
+		enc := hpack.NewEncoder(&block)
+		enc.WriteField(hpack.HeaderField{Name: ":method", Value: p.verb()})
+		enc.WriteField(hpack.HeaderField{Name: ":scheme", Value: scheme})
+		enc.WriteField(hpack.HeaderField{Name: ":authority", Value: authority})
+		enc.WriteField(hpack.HeaderField{Name: ":path", Value: "/"})
+		err := framer.WriteHeaders(http2.HeadersFrameParam{
+			StreamID:      h2Stream,
+			BlockFragment: block.Bytes(),
+			EndStream:     true,
+			EndHeaders:    true,
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	res := &httpResult{ContentLength: -1}
+	gotSettings, gotHeaders := false, false
+	for {
+		frame, err := framer.ReadFrame()
+		if err != nil {
+			if gotHeaders {
+				return res, nil
+			}
+			return nil, err
+		}
+
+		switch f := frame.(type) {
+		case *http2.SettingsFrame:
+			if f.IsAck() {
+				continue
+			}
+			gotSettings = true
+			f.ForeachSetting(func(s http2.Setting) error {
+				res.Settings = append(res.Settings, h2Setting{s.ID, s.Val})
+				return nil
+			})
+			framer.WriteSettingsAck()
+		case *http2.PingFrame:
+			if !f.IsAck() {
+				framer.WritePing(true, f.Data)
+			}
+		case *http2.MetaHeadersFrame:
+			if f.StreamID != h2Stream || gotHeaders {
+				continue
+			}
+			gotHeaders = true
+			res.Header = make(http.Header)
+			for _, hf := range f.RegularFields() {
+				res.Header.Add(hf.Name, hf.Value)
+			}
+			res.StatusCode, _ = strconv.Atoi(f.PseudoValue("status"))
+			res.Status = fmt.Sprintf("%d %s", res.StatusCode, http.StatusText(res.StatusCode))
+			if n, err := strconv.ParseInt(res.Header.Get("Content-Length"), 10, 64); err == nil {
+				res.ContentLength = n
+			}
+			if f.StreamEnded() || p.BodyLimit == 0 {
+				return res, nil
+			}
+		case *http2.DataFrame:
+			if f.StreamID != h2Stream {
+				continue
+			}
+			if room := p.BodyLimit - int64(len(res.Body)); room > 0 {
+				data := f.Data()
+				if int64(len(data)) > room {
+					data = data[:room]
+				}
+				res.Body = append(res.Body, data...)
+			}
+			if f.StreamEnded() || int64(len(res.Body)) >= p.BodyLimit {
+				return res, nil
+			}
+		case *http2.RSTStreamFrame:
+			if f.StreamID == h2Stream {
+				return nil, fmt.Errorf("stream cancelado: %v", f.ErrCode)
+			}
+		case *http2.GoAwayFrame:
+			if !gotHeaders {
+				return nil, fmt.Errorf("GOAWAY: %v", f.ErrCode)
+			}
+			return res, nil
+		}
+
+		if !gotSettings && !gotHeaders && frame.Header().Type != http2.FrameSettings {
+			return nil, errors.New("el servidor no inició HTTP/2 con SETTINGS")
+		}
+	}
+}
@@ -0,0 +1,379 @@
+package cmd
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Pablo0303/Alama/pkg/cdn"
+	"github.com/Pablo0303/Alama/pkg/matcher"
+)
+
+// Niveles de verificación de certificados aceptados por --tls-verify.
+const (
+	tlsVerifyNone  = "none"  // acepta cualquier certificado
+	tlsVerifyChain = "chain" // valida la cadena pero no el nombre
+	tlsVerifyFull  = "full"  // valida la cadena y el nombre (SNI)
+)
+
+// httpProbe agrupa las opciones de una sonda HTTP/HTTPS contra una IP o host.
+type httpProbe struct {
+	Scheme    string // http o https
+	Port      int    // 0 usa el puerto por defecto del esquema
+	SNI       string // ServerName TLS, independiente de la IP marcada
+	Host      string // cabecera Host, vacía usa la del objetivo
+	Path      string // ruta de la solicitud, vacía pide la raíz
+	TLSVerify string
+	Proxy     *outbound // nil conecta directamente
+	Verb      string
+	Timeout   time.Duration
+	BodyLimit int64 // bytes del cuerpo a conservar en el resultado
+
+	// MaxRedirects es el número de redirecciones a seguir; 0 no sigue
+	// ninguna y devuelve la primera respuesta.
+	MaxRedirects int
+
+	// HTTP2 hace que Fetch intente HTTP/2 con FetchH2.
+	HTTP2 bool
+}
+
+// defaultRedirects es el valor por defecto de --follow-redirects, igual al
+// límite de net/http.
+const defaultRedirects = "10"
+
+// parseRedirects interpreta el valor de --follow-redirects: un número o none.
+func parseRedirects(s string) (int, error) {
+	if s == "none" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("valor de --follow-redirects inválido: %s", s)
+	}
+	return n, nil
+}
+
+// redirectHop es una respuesta de redirección de la cadena.
+type redirectHop struct {
+	StatusCode int
+	Location   string
+}
+
+func (h redirectHop) String() string {
+	return fmt.Sprintf("%d -> %s", h.StatusCode, h.Location)
+}
+
+// httpResult es la respuesta obtenida por una sonda HTTP.
+type httpResult struct {
+	Target        string
+	StatusCode    int
+	Status        string
+	Header        http.Header
+	Body          []byte
+	ContentLength int64
+	Latency       time.Duration
+	Chain         []redirectHop // redirecciones recibidas, en orden
+	FinalURL      *url.URL      // destino final de la cadena
+	CertIssuer    string        // emisor del certificado, si la conexión es TLS
+	Provider      string        // proveedor de CDN que atendió la solicitud
+	Proxy         string        // proxy de --proxy-file por el que salió
+	Proto         string        // protocolo negociado por FetchH2
+	Settings      []h2Setting   // SETTINGS del servidor HTTP/2
+}
+
+// FinalHost devuelve el host del destino final, sin puerto.
+func (r *httpResult) FinalHost() string {
+	if r.FinalURL == nil {
+		return ""
+	}
+	return r.FinalURL.Hostname()
+}
+
+// chainString da formato a la cadena de redirecciones.
+func (r *httpResult) chainString() string {
+	hops := make([]string, 0, len(r.Chain))
+	for _, hop := range r.Chain {
+		hops = append(hops, hop.String())
+	}
+	return strings.Join(hops, " | ")
+}
+
+// Server devuelve la cabecera Server de la respuesta.
+func (r *httpResult) Server() string {
+	return r.Header.Get("Server")
+}
+
+// matchResponse adapta el resultado al formato evaluado por los matchers.
+func (r *httpResult) matchResponse() *matcher.Response {
+	return &matcher.Response{
+		StatusCode:    r.StatusCode,
+		Header:        r.Header,
+		Body:          r.Body,
+		ContentLength: r.ContentLength,
+	}
+}
+
+// URL construye la URL a solicitar para el objetivo dado.
+func (p *httpProbe) URL(target string) string {
+	scheme := p.Scheme
+	if scheme == "" {
+		scheme = "http"
+	}
+
+	host := target
+	if _, _, err := net.SplitHostPort(target); err != nil {
+		if p.Port > 0 {
+			host = net.JoinHostPort(target, strconv.Itoa(p.Port))
+		} else if ip := net.ParseIP(target); ip != nil && ip.To4() == nil {
+			host = "[" + target + "]"
+		}
+	}
+
+	return scheme + "://" + host + p.Path
+}
+
+// forDomain devuelve la sonda para un objetivo obtenido con --resolve: usa
+// domain como Host y SNI salvo que ya estén indicados.
+func (p *httpProbe) forDomain(domain string) *httpProbe {
+	if domain == "" {
+		return p
+	}
+	probe := *p
+	if probe.Host == "" {
+		probe.Host = domain
+	}
+	if probe.SNI == "" {
+		probe.SNI = domain
+	}
+	return &probe
+}
+
+// Client crea el cliente HTTP con la configuración TLS y de proxy de la sonda.
+// El cliente no sigue redirecciones: de eso se encarga Fetch.
+func (p *httpProbe) Client() (*http.Client, error) {
+	tlsConfig, err := newTLSConfig(p.SNI, p.TLSVerify)
+	if err != nil {
+		return nil, err
+	}
+
+	transport := &http.Transport{
+		TLSClientConfig:   tlsConfig,
+		DisableKeepAlives: true,
+	}
+
+	p.Proxy.configureTransport(transport)
+
+	return &http.Client{
+		Timeout:   p.Timeout,
+		Transport: transport,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}, nil
+}
+
+// fetchOnce envía una única solicitud y lee hasta BodyLimit bytes del cuerpo.
+// Si la respuesta es una redirección devuelve también su destino.
+func (p *httpProbe) fetchOnce(client *http.Client, verb, rawURL string) (*httpResult, *url.URL, error) {
+	req, err := http.NewRequest(verb, rawURL, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	if p.Host != "" {
+		req.Host = p.Host
+	}
+
+	start := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+
+	res := &httpResult{
+		StatusCode:    resp.StatusCode,
+		Status:        resp.Status,
+		Header:        resp.Header,
+		ContentLength: resp.ContentLength,
+		Latency:       time.Since(start),
+		FinalURL:      req.URL,
+	}
+	if p.BodyLimit > 0 {
+		res.Body, _ = io.ReadAll(io.LimitReader(resp.Body, p.BodyLimit))
+	}
+	if resp.TLS != nil && len(resp.TLS.PeerCertificates) > 0 {
+		res.CertIssuer = resp.TLS.PeerCertificates[0].Issuer.String()
+	}
+	if !isRedirect(resp.StatusCode) {
+		return res, nil, nil
+	}
+	loc, err := resp.Location()
+	if err != nil {
+		return res, nil, nil
+	}
+	res.FinalURL = loc
+
+	return res, loc, nil
+}
+
+// isRedirect indica si el código de estado es una redirección con Location.
+func isRedirect(code int) bool {
+	switch code {
+	case http.StatusMovedPermanently, http.StatusFound, http.StatusSeeOther,
+		http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+		return true
+	}
+	return false
+}
+
+// Fetch envía la solicitud al objetivo y sigue hasta MaxRedirects
+// redirecciones, registrando la cadena. Si una redirección no se puede
+// seguir se devuelve la última respuesta obtenida.
+func (p *httpProbe) Fetch(target string) (*httpResult, error) {
+	if p.HTTP2 {
+		return p.FetchH2(target)
+	}
+
+	// Toda la cadena sale por el mismo proxy del pool.
+	picked := *p
+	picked.Proxy = p.Proxy.pick()
+	p = &picked
+
+	client, err := p.Client()
+	if err != nil {
+		return nil, err
+	}
+
+	verb := p.Verb
+	if verb == "" {
+		verb = http.MethodGet
+	}
+
+	// El origen es el host escaneado: fetchOnce deja en FinalURL el destino
+	// de la redirección.
+	start, err := url.Parse(p.URL(target))
+	if err != nil {
+		return nil, err
+	}
+	origin := start.Host
+
+	res, loc, err := p.fetchOnce(client, verb, start.String())
+	if err != nil {
+		return nil, err
+	}
+
+	// El proveedor se deduce de la primera respuesta y de la IP marcada,
+	// que son las del host escaneado; las siguientes pueden venir de otros
+	// sitios.
+	provider := cdn.Classify(cdn.Evidence{
+		Header:     res.Header,
+		CertIssuer: res.CertIssuer,
+		IP:         net.ParseIP(start.Hostname()),
+	})
+
+	var chain []redirectHop
+	for loc != nil {
+		chain = append(chain, redirectHop{res.StatusCode, loc.String()})
+		if len(chain) > p.MaxRedirects {
+			break
+		}
+
+		// Fuera del host original el SNI y el Host forzados ya no aplican.
+		next, nextClient := p, client
+		if loc.Host != origin {
+			plain := *p
+			plain.SNI, plain.Host = "", ""
+			if nextClient, err = plain.Client(); err != nil {
+				break
+			}
+			next = &plain
+		}
+		if res.StatusCode != http.StatusTemporaryRedirect && res.StatusCode != http.StatusPermanentRedirect && verb != http.MethodHead {
+			verb = http.MethodGet
+		}
+
+		nextRes, nextLoc, err := next.fetchOnce(nextClient, verb, loc.String())
+		if err != nil {
+			break
+		}
+		nextRes.Latency += res.Latency
+		res, loc = nextRes, nextLoc
+	}
+
+	res.Target = target
+	res.Chain = chain
+	res.Provider = provider
+	res.Proxy = p.Proxy.Label()
+	return res, nil
+}
+
+// formatHTTPHit da formato a un host activo con su respuesta HTTP, si la hay.
+func formatHTTPHit(ip string, res *httpResult) string {
+	if res == nil {
+		return fmt.Sprintf("%s -  - ", ip)
+	}
+	s := fmt.Sprintf("%s - %s - %s", ip, res.Server(), res.Status)
+	if res.Provider != cdn.Unknown {
+		s += " [" + res.Provider + "]"
+	}
+	if len(res.Chain) > 0 {
+		s += " - " + res.chainString()
+	}
+	if res.Proxy != "" {
+		s += " (vía " + res.Proxy + ")"
+	}
+	return s
+}
+
+// newTLSConfig devuelve la configuración TLS para el ServerName y el nivel de
+// verificación indicados.
+func newTLSConfig(serverName, verify string) (*tls.Config, error) {
+	config := &tls.Config{ServerName: serverName}
+
+	switch verify {
+	case "", tlsVerifyNone:
+		config.InsecureSkipVerify = true
+	case tlsVerifyChain:
+		// Se omite la verificación estándar para no exigir que el nombre
+		// coincida, pero la cadena se valida contra las raíces del sistema.
+		config.InsecureSkipVerify = true
+		config.VerifyPeerCertificate = verifyCertChain
+	case tlsVerifyFull:
+	default:
+		return nil, fmt.Errorf("nivel de verificación TLS inválido: %s", verify)
+	}
+
+	return config, nil
+}
+
+// verifyCertChain valida la cadena de certificados sin comprobar el nombre.
+func verifyCertChain(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+	if len(rawCerts) == 0 {
+		return errors.New("el servidor no presentó certificados")
+	}
+
+	certs := make([]*x509.Certificate, 0, len(rawCerts))
+	for _, raw := range rawCerts {
+		cert, err := x509.ParseCertificate(raw)
+		if err != nil {
+			return err
+		}
+		certs = append(certs, cert)
+	}
+
+	intermediates := x509.NewCertPool()
+	for _, cert := range certs[1:] {
+		intermediates.AddCert(cert)
+	}
+
+	_, err := certs[0].Verify(x509.VerifyOptions{Intermediates: intermediates})
+	return err
+}
@@ -0,0 +1,104 @@
+package cmd
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// hostRecorder guarda la cabecera Host de cada solicitud por ruta.
+type hostRecorder struct {
+	mu    sync.Mutex
+	hosts map[string]string
+}
+
+func (r *hostRecorder) record(req *http.Request) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.hosts == nil {
+		r.hosts = make(map[string]string)
+	}
+	r.hosts[req.URL.Path] = req.Host
+}
+
+func (r *hostRecorder) get(path string) string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.hosts[path]
+}
+
+func TestFetchRedirectChainDropsForcedHost(t *testing.T) {
+	var seenA, seenB hostRecorder
+	var srvB *httptest.Server
+
+	// A redirige a B, B vuelve a A y A responde.
+	srvA := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seenA.record(r)
+		if r.URL.Path == "/fin" {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		http.Redirect(w, r, srvB.URL+"/b", http.StatusFound)
+	}))
+	defer srvA.Close()
+	srvB = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seenB.record(r)
+		http.Redirect(w, r, srvA.URL+"/fin", http.StatusFound)
+	}))
+	defer srvB.Close()
+
+	probe := &httpProbe{
+		Scheme:       "http",
+		Host:         "forzado.example",
+		Timeout:      2 * time.Second,
+		MaxRedirects: 5,
+	}
+	res, err := probe.Fetch(strings.TrimPrefix(srvA.URL, "http://"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if res.StatusCode != http.StatusOK {
+		t.Errorf("estado = %d, se esperaba 200", res.StatusCode)
+	}
+	if len(res.Chain) != 2 {
+		t.Fatalf("cadena = %v, se esperaban 2 saltos", res.Chain)
+	}
+	if got := seenA.get("/"); got != "forzado.example" {
+		t.Errorf("Host en el origen = %q, se esperaba el forzado", got)
+	}
+	if got, want := seenB.get("/b"), strings.TrimPrefix(srvB.URL, "http://"); got != want {
+		t.Errorf("Host en otro host = %q, se esperaba %q", got, want)
+	}
+	if got := seenA.get("/fin"); got != "forzado.example" {
+		t.Errorf("Host al volver al origen = %q, se esperaba el forzado", got)
+	}
+	if res.Target != strings.TrimPrefix(srvA.URL, "http://") {
+		t.Errorf("Target = %q", res.Target)
+	}
+}
+
+func TestFetchStopsAtMaxRedirects(t *testing.T) {
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, srv.URL+r.URL.Path+"x", http.StatusMovedPermanently)
+	}))
+	defer srv.Close()
+
+	for _, max := range []int{0, 1, 3} {
+		probe := &httpProbe{Scheme: "http", Timeout: 2 * time.Second, MaxRedirects: max}
+		res, err := probe.Fetch(strings.TrimPrefix(srv.URL, "http://"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.StatusCode != http.StatusMovedPermanently {
+			t.Errorf("max %d: estado = %d", max, res.StatusCode)
+		}
+		if len(res.Chain) != max+1 {
+			t.Errorf("max %d: cadena de %d saltos, se esperaban %d", max, len(res.Chain), max+1)
+		}
+	}
+}
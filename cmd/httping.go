@@ -1,11 +1,8 @@
 package cmd
 
 import (
-	"bufio"
 	"fmt"
-	"net"
 	"net/http"
-	"net/url"
 	"os"
 	"strings"
 	"sync"
@@ -13,6 +10,9 @@ import (
 
 	"github.com/spf13/cobra"
 	"github.com/fatih/color"
+
+	"github.com/Pablo0303/Alama/pkg/cdn"
+	"github.com/Pablo0303/Alama/pkg/queuescanner"
 )
 
 // httpingCmd representa el comando httping
@@ -24,16 +24,23 @@ var httpingCmd = &cobra.Command{
 }
 
 var (
-	httpingFlagCIDR     string
-	httpingFlagFile     string
-	httpingFlagOutput   string
-	httpingFlagTimeout  int
-	httpingFlagDelay    int
-	httpingFlagCount    int
-	httpingFlagThreads  int
-	httpingFlagStatus   string
-	httpingFlagProxy    string
-	httpingFlagHTTPVerb string
+	httpingFlagCIDR           string
+	httpingFlagFile           string
+	httpingFlagOutput         string
+	httpingFlagTimeout        int
+	httpingFlagDelay          int
+	httpingFlagCount          int
+	httpingFlagThreads        int
+	httpingFlagHTTPVerb       string
+	httpingFlagScheme         string
+	httpingFlagPort           int
+	httpingFlagSNI            string
+	httpingFlagVerify         string
+	httpingFlagMatch          matchFlags
+	httpingFlagRedirect       string
+	httpingFlagProviderRanges string
+	httpingFlagHTTP2          bool
+	httpingFlagResolve        bool
 )
 
 func init() {
@@ -46,43 +53,46 @@ func init() {
 	httpingCmd.Flags().IntVarP(&httpingFlagDelay, "delay", "d", 250, "Retraso entre escaneos en milisegundos")
 	httpingCmd.Flags().IntVarP(&httpingFlagCount, "count", "n", 1, "Número de intentos de escaneo por IP")
 	httpingCmd.Flags().IntVarP(&httpingFlagThreads, "threads", "T", 50, "Número de hilos concurrentes")
-	httpingCmd.Flags().StringVarP(&httpingFlagStatus, "status", "s", "", "Códigos de estado HTTP a mostrar (ej. 200,500)")
-	httpingCmd.Flags().StringVarP(&httpingFlagProxy, "proxy", "x", "", "Proxy y puerto a usar (ej., 192.168.1.1:8080)")
+	addProviderRangesFlag(httpingCmd, &httpingFlagProviderRanges)
 	httpingCmd.Flags().StringVarP(&httpingFlagHTTPVerb, "httpverb", "v", "GET", "HTTP Verb: Only GET or HEAD supported at the moment")
+	httpingCmd.Flags().StringVar(&httpingFlagScheme, "scheme", "http", "Esquema a usar: http o https")
+	httpingCmd.Flags().IntVarP(&httpingFlagPort, "port", "p", 0, "Puerto de destino (0 usa el puerto por defecto del esquema)")
+	httpingCmd.Flags().StringVar(&httpingFlagSNI, "sni", "", "ServerName TLS a enviar, independiente de la IP")
+	httpingCmd.Flags().StringVar(&httpingFlagVerify, "tls-verify", tlsVerifyNone, "Verificación de certificados: none, chain (sin validar el nombre) o full")
+	addMatchFlags(httpingCmd, &httpingFlagMatch)
+	addRedirectFlag(httpingCmd, &httpingFlagRedirect)
+	addResolveFlag(httpingCmd, &httpingFlagResolve)
+	httpingCmd.Flags().BoolVar(&httpingFlagHTTP2, "http2", false, "Intenta HTTP/2 (ALPN h2 con https, h2c en claro) y muestra el protocolo y los SETTINGS")
 }
 
 func httpingRun(cmd *cobra.Command, args []string) {
-	var ips []string
-
-	// Procesar el rango CIDR
-	if httpingFlagCIDR != "" {
-		ip, ipnet, err := net.ParseCIDR(httpingFlagCIDR)
-		if err != nil {
-			fmt.Println("Rango CIDR inválido:", err)
-			return
-		}
-		for ip := ip.Mask(ipnet.Mask); ipnet.Contains(ip); incrementIP(ip) {
-			ips = append(ips, ip.String())
-		}
+	if httpingFlagScheme != "http" && httpingFlagScheme != "https" {
+		fmt.Println("Esquema inválido:", httpingFlagScheme)
+		return
 	}
+	if _, err := newTLSConfig(httpingFlagSNI, httpingFlagVerify); err != nil {
+		fmt.Println(err)
+		return
+	}
+	filter, err := httpingFlagMatch.compile()
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	redirects, err := parseRedirects(httpingFlagRedirect)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	probe := httpingProbe(filter.BodyLimit(), redirects)
 
-	// Procesar el archivo de hosts
-	if httpingFlagFile != "" {
-		file, err := os.Open(httpingFlagFile)
-		if err != nil {
-			fmt.Println("Error al abrir el archivo:", err)
-			return
-		}
-		defer file.Close()
-
-		scanner := bufio.NewScanner(file)
-		for scanner.Scan() {
-			ips = append(ips, scanner.Text())
-		}
-		if err := scanner.Err(); err != nil {
-			fmt.Println("Error al leer el archivo:", err)
-			return
-		}
+	ips, err := loadTargets(httpingFlagCIDR, httpingFlagFile, httpingFlagProviderRanges)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	if httpingFlagResolve {
+		ips.Resolve(httpingFlagThreads)
 	}
 
 	// Escaneo de hosts
@@ -94,9 +104,10 @@ func httpingRun(cmd *cobra.Command, args []string) {
 	validResultsCount := 0 // Contador de resultados válidos
 
 	// Mostrar el progreso en tiempo real
-	totalIPs := len(ips)
+	totalIPs := ips.Len()
+	tracker := queuescanner.NewCtx(totalIPs)
 
-	for index, ip := range ips {
+	ips.Each(func(index int, ip string) {
 		wg.Add(1)
 		sem <- struct{}{}
 		go func(ip string, index int) {
@@ -104,13 +115,36 @@ func httpingRun(cmd *cobra.Command, args []string) {
 			defer func() { <-sem }()
 
 			// Hacer la solicitud HTTP
-			statusCode := scanHTTP(ip, httpingFlagTimeout)
+			var res *httpResult
+			var keep bool
+			tracker.Track(func() (bool, error) {
+				var err error
+				res, err = scanHTTP(probe, ip)
+				keep = res != nil && filter.Keep(res)
+				return keep, err
+			})
 
 			mu.Lock() // Asegurarse de que no haya interferencia al acceder a `results`
-			if statusCode != 0 {
-				if httpingFlagStatus == "" || strings.Contains(httpingFlagStatus, fmt.Sprint(statusCode)) {
-					// Solo agregar si el estado coincide
-					results = append(results, fmt.Sprintf("%-20s %s", ip, green(fmt.Sprint(statusCode)))) // Mostrar IP y estado en verde
+			if res != nil {
+				if keep {
+					// Solo agregar si la respuesta cumple los filtros
+					result := fmt.Sprintf("%-20s %s %dms", ip+domainSuffix(ip), green(fmt.Sprint(res.StatusCode)), res.Latency.Milliseconds()) // Mostrar IP, estado y latencia
+					if res.Provider != cdn.Unknown {
+						result += " [" + res.Provider + "]"
+					}
+					if len(res.Chain) > 0 {
+						result += " " + res.chainString()
+					}
+					if res.Proto != "" {
+						result += " " + res.Proto
+						if len(res.Settings) > 0 {
+							result += " [" + res.settingsString() + "]"
+						}
+					}
+					if res.Proxy != "" {
+						result += " (vía " + res.Proxy + ")"
+					}
+					results = append(results, result)
 					validResultsCount++ // Incrementar el contador de resultados válidos
 				}
 			}
@@ -123,7 +157,7 @@ func httpingRun(cmd *cobra.Command, args []string) {
 				time.Sleep(time.Duration(httpingFlagDelay) * time.Millisecond)
 			}
 		}(ip, index)
-	}
+	})
 	wg.Wait()
 
 	// Asegurarse de que el progreso se complete al final
@@ -134,7 +168,7 @@ func httpingRun(cmd *cobra.Command, args []string) {
 	// Imprimir resultados en la consola
 	if len(results) > 0 {
 		for _, result := range results {
-			fmt.Println(result) // Mostrar solo los resultados que coinciden con -s
+			fmt.Println(result) // Mostrar solo los resultados que cumplen los filtros
 		}
 	} else {
 		// Si no hay resultados, imprimir un mensaje
@@ -150,39 +184,32 @@ func httpingRun(cmd *cobra.Command, args []string) {
 	}
 }
 
-// scanHTTP realiza una solicitud HTTP y devuelve el código de estado.
-func scanHTTP(ip string, timeout int) int {
-	client := http.Client{
-		Timeout: time.Duration(timeout) * time.Second,
-	}
-
-	// Configurar proxy si se especifica
-	if httpingFlagProxy != "" {
-		proxyURL, err := url.Parse(httpingFlagProxy) // Usar el paquete url para parsear
-		if err == nil {
-			transport := &http.Transport{Proxy: http.ProxyURL(proxyURL)}
-			client.Transport = transport
-		}
+// httpingProbe construye la sonda HTTP a partir de las banderas.
+func httpingProbe(bodyLimit int64, redirects int) *httpProbe {
+	probe := &httpProbe{
+		Scheme:       httpingFlagScheme,
+		Port:         httpingFlagPort,
+		SNI:          httpingFlagSNI,
+		TLSVerify:    httpingFlagVerify,
+		Proxy:        probeOutbound,
+		Verb:         http.MethodGet,
+		Timeout:      time.Duration(httpingFlagTimeout) * time.Second,
+		BodyLimit:    bodyLimit,
+		MaxRedirects: redirects,
+		HTTP2:        httpingFlagHTTP2,
 	}
-
-	var req *http.Request
-	var err error
-
-	// Crear la solicitud según el verbo HTTP especificado
 	if httpingFlagHTTPVerb == "HEAD" {
-		req, err = http.NewRequest("HEAD", "http://"+ip, nil)
-	} else {
-		req, err = http.NewRequest("GET", "http://"+ip, nil)
-	}
-
-	if err != nil {
-		return 0 // Retornar 0 si hay error en la creación de la solicitud
+		probe.Verb = http.MethodHead
 	}
+	return probe
+}
 
-	resp, err := client.Do(req)
+// scanHTTP realiza una solicitud HTTP y devuelve la respuesta, o nil y el
+// error si falla.
+func scanHTTP(probe *httpProbe, ip string) (*httpResult, error) {
+	res, err := probe.forDomain(resolvedDomain(ip)).Fetch(ip)
 	if err != nil {
-		return 0 // Retornar 0 si hay error en la solicitud
+		return nil, err // Retornar nil si hay error en la solicitud
 	}
-	defer resp.Body.Close()
-	return resp.StatusCode
+	return res, nil
 }
@@ -0,0 +1,140 @@
+package cmd
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/Pablo0303/Alama/pkg/matcher"
+)
+
+// matchFlags agrupa las banderas de filtrado de respuestas HTTP.
+type matchFlags struct {
+	Status    string
+	Match     string
+	Filter    string
+	FinalHost string
+	Provider  string
+	BodyLimit int
+}
+
+// addMatchFlags registra las banderas de filtrado en el comando.
+func addMatchFlags(cmd *cobra.Command, f *matchFlags) {
+	cmd.Flags().StringVarP(&f.Status, "status", "s", "", "Códigos de estado HTTP a mostrar (ej. 200,2xx,300-399)")
+	cmd.Flags().StringVar(&f.Match, "match", "", "Expresión que deben cumplir las respuestas (ej. 'status:2xx and header:Server~cloudflare')")
+	cmd.Flags().StringVar(&f.Filter, "filter", "", "Expresión que descarta las respuestas que la cumplen")
+	cmd.Flags().StringVar(&f.FinalHost, "final-host", "", "Muestra solo las respuestas cuyo destino final coincide con el patrón (ej. '*.example.com')")
+	cmd.Flags().StringVar(&f.Provider, "provider", "", "Muestra solo los hosts de estos proveedores de CDN (ej. fastly,cloudflare)")
+	cmd.Flags().IntVar(&f.BodyLimit, "body-limit", 64, "KB del cuerpo a examinar con body: y body~")
+}
+
+// addRedirectFlag registra --follow-redirects en el comando.
+func addRedirectFlag(cmd *cobra.Command, p *string) {
+	cmd.Flags().StringVar(p, "follow-redirects", defaultRedirects, "Redirecciones a seguir: un número o none")
+}
+
+// responseFilter decide qué respuestas HTTP se muestran.
+type responseFilter struct {
+	match     matcher.Matcher
+	filter    matcher.Matcher
+	finalHost string
+	providers map[string]bool
+	bodyLimit int64
+}
+
+// compile interpreta las banderas de filtrado.
+func (f *matchFlags) compile() (*responseFilter, error) {
+	rf := &responseFilter{}
+
+	var and []matcher.Matcher
+	if f.Status != "" {
+		m, err := matcher.ParseStatus(f.Status)
+		if err != nil {
+			return nil, err
+		}
+		and = append(and, m)
+	}
+	if f.Match != "" {
+		m, err := matcher.Parse(f.Match)
+		if err != nil {
+			return nil, fmt.Errorf("--match: %w", err)
+		}
+		and = append(and, m)
+	}
+	switch len(and) {
+	case 1:
+		rf.match = and[0]
+	case 2:
+		rf.match = matcher.All(and...)
+	}
+
+	if f.Filter != "" {
+		m, err := matcher.Parse(f.Filter)
+		if err != nil {
+			return nil, fmt.Errorf("--filter: %w", err)
+		}
+		rf.filter = m
+	}
+
+	if f.FinalHost != "" {
+		if _, err := path.Match(f.FinalHost, ""); err != nil {
+			return nil, fmt.Errorf("--final-host: %w", err)
+		}
+		rf.finalHost = strings.ToLower(f.FinalHost)
+	}
+
+	for _, provider := range strings.Split(f.Provider, ",") {
+		provider = strings.ToLower(strings.TrimSpace(provider))
+		if provider == "" {
+			continue
+		}
+		if rf.providers == nil {
+			rf.providers = make(map[string]bool)
+		}
+		rf.providers[provider] = true
+	}
+
+	if (rf.match != nil && matcher.UsesBody(rf.match)) || (rf.filter != nil && matcher.UsesBody(rf.filter)) {
+		rf.bodyLimit = int64(f.BodyLimit) << 10
+	}
+
+	return rf, nil
+}
+
+// Active indica si hay algún criterio de filtrado.
+func (rf *responseFilter) Active() bool {
+	return rf.match != nil || rf.filter != nil || rf.finalHost != "" || rf.providers != nil
+}
+
+// BodyLimit devuelve cuántos bytes del cuerpo hay que leer para evaluar el
+// filtro.
+func (rf *responseFilter) BodyLimit() int64 {
+	return rf.bodyLimit
+}
+
+// Keep indica si la respuesta debe mostrarse. Sin respuesta solo se muestra
+// cuando no hay criterios de filtrado.
+func (rf *responseFilter) Keep(res *httpResult) bool {
+	if res == nil {
+		return !rf.Active()
+	}
+
+	r := res.matchResponse()
+	if rf.match != nil && !rf.match.Match(r) {
+		return false
+	}
+	if rf.filter != nil && rf.filter.Match(r) {
+		return false
+	}
+	if rf.providers != nil && !rf.providers[res.Provider] {
+		return false
+	}
+	if rf.finalHost != "" {
+		if ok, _ := path.Match(rf.finalHost, strings.ToLower(res.FinalHost())); !ok {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,140 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/Pablo0303/Alama/pkg/queuescanner"
+)
+
+var (
+	globalFlagMetrics     string
+	globalFlagMetricsFile string
+)
+
+// metricsFileInterval es cada cuánto se reescribe --metrics-file.
+const metricsFileInterval = time.Second
+
+var metricsFileMu sync.Mutex
+
+func addMetricsFlags(cmd *cobra.Command) {
+	cmd.PersistentFlags().StringVar(&globalFlagMetrics, "metrics", "", "Dirección en la que servir /metrics para Prometheus durante el escaneo (p. ej. 127.0.0.1:9100)")
+	cmd.PersistentFlags().StringVar(&globalFlagMetricsFile, "metrics-file", "", "Archivo JSON en el que guardar las métricas del escaneo cada segundo")
+	cmd.PersistentFlags().MarkHidden("metrics-file")
+}
+
+// metricsProbe es el nombre de la sonda de un comando en las métricas:
+// "scan sni" pasa a scan_sni.
+func metricsProbe(cmd *cobra.Command) string {
+	path := strings.TrimPrefix(cmd.CommandPath(), cmd.Root().Name()+" ")
+	return strings.ReplaceAll(path, " ", "_")
+}
+
+// initMetrics etiqueta los escáneres con el comando y, según las banderas,
+// sirve /metrics y guarda las métricas en un archivo.
+func initMetrics(cmd *cobra.Command) {
+	queuescanner.Probe = metricsProbe(cmd)
+
+	if globalFlagMetrics != "" {
+		listener, err := net.Listen("tcp", globalFlagMetrics)
+		cobra.CheckErr(err)
+		mux := http.NewServeMux()
+		mux.Handle("GET /metrics", queuescanner.Handler())
+		go http.Serve(listener, mux)
+		fmt.Fprintf(os.Stderr, "Métricas en http://%s/metrics\n", listener.Addr())
+	}
+
+	if globalFlagMetricsFile != "" {
+		go func() {
+			for range time.Tick(metricsFileInterval) {
+				writeMetricsFile()
+			}
+		}()
+	}
+}
+
+// writeMetricsFile guarda las métricas actuales en --metrics-file. Se
+// escribe en un archivo temporal y se renombra para que quien lo lea nunca
+// vea uno a medias.
+func writeMetricsFile() {
+	if globalFlagMetricsFile == "" {
+		return
+	}
+	metricsFileMu.Lock()
+	defer metricsFileMu.Unlock()
+
+	data, err := json.Marshal(queuescanner.Snapshot())
+	if err != nil {
+		return
+	}
+	tmp := globalFlagMetricsFile + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return
+	}
+	os.Rename(tmp, globalFlagMetricsFile)
+}
+
+// readMetricsFile lee las métricas que guardó otro proceso con
+// --metrics-file.
+func readMetricsFile(filename string) []queuescanner.Stats {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil
+	}
+	var stats []queuescanner.Stats
+	if json.Unmarshal(data, &stats) != nil {
+		return nil
+	}
+	return stats
+}
+
+// serveGlobalArgs son las banderas globales que serve pasa a cada escaneo,
+// sin --metrics: cada escaneo guarda sus métricas en un archivo y serve las
+// sirve todas juntas.
+func serveGlobalArgs() []string {
+	var args []string
+	for _, arg := range globalJobArgs() {
+		if !strings.HasPrefix(arg, "--metrics=") {
+			args = append(args, arg)
+		}
+	}
+	return args
+}
+
+// handleMetrics sirve las métricas de todos los escaneos, con la etiqueta
+// job, y cuántos escaneos hay en cada estado.
+func (s *scanServer) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	jobs := append([]*serveJob(nil), s.list...)
+	s.mu.Unlock()
+
+	var stats []queuescanner.Stats
+	counts := make(map[string]int)
+	for _, j := range jobs {
+		status := j.Info().Status
+		counts[status]++
+		for _, st := range readMetricsFile(j.metrics) {
+			st.Labels = map[string]string{"job": j.id}
+			if status != serveRunning {
+				// El último archivo se guardó con el escaneo en marcha.
+				st.Rate, st.InFlight = 0, 0
+			}
+			stats = append(stats, st)
+		}
+	}
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	fmt.Fprint(w, "# HELP alama_serve_jobs Escaneos de serve por estado.\n# TYPE alama_serve_jobs gauge\n")
+	for _, status := range []string{serveQueued, serveRunning, serveDone, serveFailed, serveCanceled} {
+		fmt.Fprintf(w, "alama_serve_jobs{status=%q} %d\n", status, counts[status])
+	}
+	queuescanner.WriteMetrics(w, stats)
+}
@@ -0,0 +1,257 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	getproxied "github.com/rapid7/go-get-proxied/proxy"
+	"github.com/spf13/cobra"
+
+	"github.com/Pablo0303/Alama/pkg/proxydial"
+)
+
+var (
+	globalFlagProxy       string
+	globalFlagSystemProxy bool
+)
+
+// probeOutbound es la salida que usan todas las sondas TCP, TLS y HTTP. Es nil
+// cuando las conexiones son directas.
+var probeOutbound *outbound
+
+// proxySchemes son los esquemas aceptados por --proxy.
+var proxySchemes = map[string]bool{
+	"http":    true,
+	"socks4":  true,
+	"socks4a": true,
+	"socks5":  true,
+	"socks5h": true,
+}
+
+// outbound decide por dónde salen las conexiones de las sondas: un proxy
+// fijo, el proxy del sistema o ninguno.
+type outbound struct {
+	Proxy  *url.URL // proxy fijo; tiene prioridad sobre System
+	System bool     // usa HTTP_PROXY, HTTPS_PROXY, ALL_PROXY y NO_PROXY
+
+	system getproxied.Provider
+	pool   *proxyPool // proxies de --proxy-file
+	member *poolProxy // proxy del pool elegido por pick
+	err    error      // error de selección, devuelto al conectar
+}
+
+// proxyCheckTimeout es el plazo de cada comprobación de un proxy del pool.
+const proxyCheckTimeout = 10 * time.Second
+
+// parseProxy interpreta el valor de --proxy. Sin esquema se asume http.
+func parseProxy(s string) (*url.URL, error) {
+	if !strings.Contains(s, "://") {
+		s = "http://" + s
+	}
+	u, err := url.Parse(s)
+	if err != nil {
+		return nil, fmt.Errorf("proxy inválido: %w", err)
+	}
+	u.Scheme = strings.ToLower(u.Scheme)
+	if !proxySchemes[u.Scheme] {
+		return nil, fmt.Errorf("esquema de proxy no soportado: %s", u.Scheme)
+	}
+	if u.Hostname() == "" || u.Port() == "" {
+		return nil, fmt.Errorf("el proxy debe indicar host y puerto: %s", s)
+	}
+	return u, nil
+}
+
+// newOutbound crea la salida a partir de un proxy (puede estar vacío) y de la
+// opción de usar el proxy del sistema. Devuelve nil si las conexiones son
+// directas.
+func newOutbound(proxy string, system bool) (*outbound, error) {
+	if proxy == "" && !system {
+		return nil, nil
+	}
+
+	o := &outbound{System: system}
+	if proxy != "" {
+		u, err := parseProxy(proxy)
+		if err != nil {
+			return nil, err
+		}
+		o.Proxy = u
+	} else {
+		o.system = getproxied.NewProvider("")
+	}
+	return o, nil
+}
+
+// initOutbound interpreta --proxy, --system-proxy y --proxy-file. Los
+// proxies de --proxy-file se comprueban antes de empezar y, si se indica un
+// intervalo, periódicamente durante el escaneo.
+func initOutbound() {
+	if globalFlagProxyFile == "" {
+		var err error
+		probeOutbound, err = newOutbound(globalFlagProxy, globalFlagSystemProxy)
+		cobra.CheckErr(err)
+		return
+	}
+
+	if globalFlagProxy != "" || globalFlagSystemProxy {
+		cobra.CheckErr("--proxy-file no se puede combinar con --proxy ni --system-proxy")
+	}
+	pool, err := loadProxyPool(globalFlagProxyFile, globalFlagProxyRotation, globalFlagProxyCheckURL)
+	cobra.CheckErr(err)
+
+	total := len(pool.proxies)
+	alive := pool.Check(proxyCheckTimeout)
+	fmt.Fprintf(os.Stderr, "Proxies disponibles: %d / %d\n", alive, total)
+	if alive == 0 {
+		cobra.CheckErr(errNoProxies)
+	}
+	if globalFlagProxyCheckInterval > 0 {
+		pool.watch(time.Duration(globalFlagProxyCheckInterval)*time.Second, proxyCheckTimeout)
+	}
+
+	probeOutbound = &outbound{pool: pool}
+}
+
+// pick fija el proxy del pool que usará una sonda. Sin pool devuelve la
+// propia salida.
+func (o *outbound) pick() *outbound {
+	if o == nil || o.pool == nil {
+		return o
+	}
+	pp, err := o.pool.pick()
+	if err != nil {
+		return &outbound{err: err}
+	}
+	return &outbound{Proxy: pp.URL, member: pp}
+}
+
+// Label identifica el proxy del pool por el que salió una sonda, o "" si no
+// se usa --proxy-file.
+func (o *outbound) Label() string {
+	if o == nil || o.member == nil {
+		return ""
+	}
+	return o.member.String()
+}
+
+// forward devuelve el Dialer con el que se llega al proxy.
+func (o *outbound) forward() proxydial.Dialer {
+	if o.member != nil {
+		return o.member
+	}
+	return proxydial.Direct
+}
+
+// proxyFor devuelve el proxy para el tráfico del protocolo indicado (http,
+// https o socks) hacia addr, o nil si la conexión es directa.
+func (o *outbound) proxyFor(protocol, addr string) *url.URL {
+	if o == nil {
+		return nil
+	}
+	if o.Proxy != nil {
+		return o.Proxy
+	}
+	if o.system == nil {
+		return nil
+	}
+
+	p := o.system.GetProxy(protocol, protocol+"://"+addr)
+	if p == nil {
+		return nil
+	}
+	u := p.URL()
+	if !proxySchemes[u.Scheme] {
+		return nil
+	}
+	return u
+}
+
+// String describe la salida para los mensajes.
+func (o *outbound) String() string {
+	switch {
+	case o == nil:
+		return "directo"
+	case o.member != nil:
+		return o.member.String()
+	case o.pool != nil:
+		return "--proxy-file"
+	case o.Proxy != nil:
+		u := *o.Proxy
+		if u.User != nil {
+			u.User = url.User(u.User.Username())
+		}
+		return u.String()
+	default:
+		return "proxy del sistema"
+	}
+}
+
+// DialContext abre una conexión con addr a través del proxy. Para el tráfico
+// que no es HTTP, el proxy del sistema se toma de ALL_PROXY y, si no existe,
+// de HTTPS_PROXY (con CONNECT).
+func (o *outbound) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	if o != nil && o.pool != nil {
+		return o.pick().DialContext(ctx, network, addr)
+	}
+	if o != nil && o.err != nil {
+		return nil, o.err
+	}
+
+	u := o.proxyFor("socks", addr)
+	if u == nil {
+		u = o.proxyFor("https", addr)
+	}
+	if u == nil {
+		return proxydial.Direct.DialContext(ctx, network, addr)
+	}
+
+	d, err := proxydial.FromURL(u, o.forward())
+	if err != nil {
+		return nil, err
+	}
+	return d.DialContext(ctx, network, addr)
+}
+
+// configureTransport hace que el transporte HTTP salga por el proxy: los
+// proxies HTTP reciben las solicitudes (y CONNECT para HTTPS) y los SOCKS
+// abren las conexiones. Con --proxy-file todo el transporte usa el mismo
+// proxy del pool.
+func (o *outbound) configureTransport(tr *http.Transport) {
+	if o == nil {
+		return
+	}
+	o = o.pick()
+
+	tr.Proxy = func(req *http.Request) (*url.URL, error) {
+		if o.err != nil {
+			return nil, o.err
+		}
+		u := o.proxyFor(req.URL.Scheme, req.URL.Host)
+		if u == nil || u.Scheme != "http" {
+			return nil, nil
+		}
+		return u, nil
+	}
+	tr.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		if o.err != nil {
+			return nil, o.err
+		}
+		u := o.proxyFor("socks", addr)
+		if u == nil || u.Scheme == "http" {
+			// Conexión directa o con el proxy HTTP elegido en tr.Proxy.
+			return o.forward().DialContext(ctx, network, addr)
+		}
+		d, err := proxydial.FromURL(u, o.forward())
+		if err != nil {
+			return nil, err
+		}
+		return d.DialContext(ctx, network, addr)
+	}
+}
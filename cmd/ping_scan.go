@@ -1,9 +1,7 @@
 package cmd
 
 import (
-	"bufio"
 	"fmt"
-	"net"
 	"os"
 	"strings"
 	"sync"
@@ -12,6 +10,8 @@ import (
 	"github.com/go-ping/ping"
 	"github.com/spf13/cobra"
 	"github.com/fatih/color"
+
+	"github.com/Pablo0303/Alama/pkg/queuescanner"
 )
 
 // pingScanCmd represents the pingScan command
@@ -22,13 +22,16 @@ var pingScanCmd = &cobra.Command{
 }
 
 var (
-	pingFlagCIDR    string
-	pingFlagFile    string
-	pingFlagOutput  string
-	pingFlagTimeout int
-	pingFlagDelay   int
-	pingFlagCount   int
-	pingFlagThreads int
+	pingFlagCIDR           string
+	pingFlagFile           string
+	pingFlagOutput         string
+	pingFlagTimeout        int
+	pingFlagDelay          int
+	pingFlagCount          int
+	pingFlagThreads        int
+	pingFlagProviderRanges string
+	pingFlagRDNS           rdnsFlags
+	pingFlagResolve        bool
 )
 
 func init() {
@@ -41,6 +44,9 @@ func init() {
 	pingScanCmd.Flags().IntVarP(&pingFlagDelay, "delay", "d", 250, "Retraso entre escaneos en milisegundos")
 	pingScanCmd.Flags().IntVarP(&pingFlagCount, "count", "n", 1, "Número de intentos de escaneo por IP")
 	pingScanCmd.Flags().IntVarP(&pingFlagThreads, "threads", "T", 50, "Número de hilos concurrentes")
+	addProviderRangesFlag(pingScanCmd, &pingFlagProviderRanges)
+	addRDNSFlags(pingScanCmd, &pingFlagRDNS)
+	addResolveFlag(pingScanCmd, &pingFlagResolve)
 }
 
 func pingScanHost(ip string, timeout, count int) bool {
@@ -61,38 +67,23 @@ func pingScanHost(ip string, timeout, count int) bool {
 }
 
 func pingScanRun(cmd *cobra.Command, args []string) {
-	var ips []string
-
-	if pingFlagCIDR != "" {
-		ip, ipnet, err := net.ParseCIDR(pingFlagCIDR)
-		if err != nil {
-			fmt.Println("Rango CIDR inválido:", err)
-			return
-		}
-		for ip := ip.Mask(ipnet.Mask); ipnet.Contains(ip); incrementIP(ip) {
-			ips = append(ips, ip.String())
-		}
+	rdns, err := pingFlagRDNS.compile()
+	if err != nil {
+		fmt.Println(err)
+		return
 	}
 
-	if pingFlagFile != "" {
-		file, err := os.Open(pingFlagFile)
-		if err != nil {
-			fmt.Println("Error al abrir el archivo:", err)
-			return
-		}
-		defer file.Close()
-
-		scanner := bufio.NewScanner(file)
-		for scanner.Scan() {
-			ips = append(ips, scanner.Text())
-		}
-		if err := scanner.Err(); err != nil {
-			fmt.Println("Error al leer el archivo:", err)
-			return
-		}
+	ips, err := loadTargets(pingFlagCIDR, pingFlagFile, pingFlagProviderRanges)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	if pingFlagResolve {
+		ips.Resolve(pingFlagThreads)
 	}
 
-	total := len(ips)
+	total := ips.Len()
+	tracker := queuescanner.NewCtx(total)
 	found := 0
 	var mu sync.Mutex
 	var wg sync.WaitGroup
@@ -100,7 +91,7 @@ func pingScanRun(cmd *cobra.Command, args []string) {
 	green := color.New(color.FgGreen).SprintFunc()
 	results := make([]string, 0)
 
-	for i, ip := range ips {
+	ips.Each(func(i int, ip string) {
 		wg.Add(1)
 		sem <- struct{}{}
 		go func(i int, ip string) {
@@ -108,11 +99,22 @@ func pingScanRun(cmd *cobra.Command, args []string) {
 			defer func() { <-sem }()
 			progress := float64(i+1) / float64(total) * 100
 
-			if pingScanHost(ip, pingFlagTimeout, pingFlagCount) {
+			var alive bool
+			tracker.Track(func() (bool, error) {
+				alive = pingScanHost(ip, pingFlagTimeout, pingFlagCount)
+				return alive, nil
+			})
+			var names []string
+			if alive && rdns != nil {
+				names = rdns.Lookup(ip)
+				alive = rdns.Keep(names)
+			}
+			if alive {
+				result := ip + domainSuffix(ip) + rdnsSuffix(names)
 				mu.Lock()
 				found++
-				results = append(results, ip)
-				fmt.Printf("\n%s\n", green(ip)) // Mostrar IP en color verde en una línea independiente
+				results = append(results, result)
+				fmt.Printf("\n%s\n", green(result)) // Mostrar IP en color verde en una línea independiente
 				mu.Unlock()
 			}
 
@@ -125,7 +127,7 @@ func pingScanRun(cmd *cobra.Command, args []string) {
 				time.Sleep(time.Duration(pingFlagDelay) * time.Millisecond)
 			}
 		}(i, ip)
-	}
+	})
 	wg.Wait()
 
 	// Asegurarse de que la línea final se muestre correctamente
@@ -0,0 +1,504 @@
+package cmd
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-ping/ping"
+	"github.com/spf13/viper"
+
+	"github.com/Pablo0303/Alama/pkg/banner"
+	"github.com/Pablo0303/Alama/pkg/cdn"
+)
+
+// stageConfig describe una etapa del pipeline, tanto en --stages como en el
+// archivo de --stages-file.
+type stageConfig struct {
+	Name     string `mapstructure:"name"`
+	Port     int    `mapstructure:"port"`     // tcp
+	Count    int    `mapstructure:"count"`    // ping
+	SNI      string `mapstructure:"sni"`      // tls
+	Host     string `mapstructure:"host"`     // http
+	Path     string `mapstructure:"path"`     // http
+	Match    string `mapstructure:"match"`    // http
+	Filter   string `mapstructure:"filter"`   // http
+	Nudge    string `mapstructure:"nudge"`    // banner
+	Optional bool   `mapstructure:"optional"` // no descarta el objetivo si falla
+}
+
+// Label devuelve el nombre de la etapa en los resultados y el embudo.
+func (c *stageConfig) Label() string {
+	label := c.Name
+	if c.Name == "tcp" {
+		label += ":" + strconv.Itoa(c.Port)
+	}
+	if c.Optional {
+		label += "?"
+	}
+	return label
+}
+
+// parseStages interpreta --stages: etapas separadas por comas con un
+// argumento opcional tras los dos puntos (tcp:443, tls:sni.example.com,
+// http:/ruta, banner:redis, ping:3). Un ? al final marca la etapa como
+// opcional.
+func parseStages(s string) ([]stageConfig, error) {
+	var configs []stageConfig
+	for _, item := range splitList(s) {
+		var c stageConfig
+		if strings.HasSuffix(item, "?") {
+			c.Optional = true
+			item = strings.TrimSuffix(item, "?")
+		}
+		name, arg, _ := strings.Cut(item, ":")
+		c.Name = strings.ToLower(name)
+
+		switch c.Name {
+		case "ping":
+			if arg != "" {
+				n, err := strconv.Atoi(arg)
+				if err != nil {
+					return nil, fmt.Errorf("número de pings inválido: %s", item)
+				}
+				c.Count = n
+			}
+		case "tcp":
+			n, err := strconv.Atoi(arg)
+			if err != nil {
+				return nil, fmt.Errorf("puerto inválido: %s", item)
+			}
+			c.Port = n
+		case "tls":
+			c.SNI = arg
+		case "http", "https":
+			c.Path = arg
+		case "banner":
+			c.Nudge = arg
+		}
+		configs = append(configs, c)
+	}
+	return configs, nil
+}
+
+// loadStages lee las etapas de la lista stages de un archivo YAML (o JSON o
+// TOML, según la extensión):
+//
+//	stages:
+//	  - name: tcp
+//	    port: 443
+//	  - name: tls
+//	    sni: example.com
+//	  - name: http
+//	    match: status:2xx and header:Server~nginx
+func loadStages(filename string) ([]stageConfig, error) {
+	v := viper.New()
+	v.SetConfigFile(filename)
+	if err := v.ReadInConfig(); err != nil {
+		return nil, err
+	}
+	var configs []stageConfig
+	if err := v.UnmarshalKey("stages", &configs); err != nil {
+		return nil, fmt.Errorf("%s: %w", filename, err)
+	}
+	if len(configs) == 0 {
+		return nil, fmt.Errorf("%s no define ninguna etapa en stages", filename)
+	}
+	return configs, nil
+}
+
+// pipelineOptions son los valores comunes a todas las etapas.
+type pipelineOptions struct {
+	Timeout   time.Duration
+	Redirects int
+	Filter    *responseFilter // filtro de las etapas http sin match propio
+}
+
+// pipelineState es lo que una etapa sabe de un objetivo y deja a las
+// siguientes: el puerto abierto, si habla TLS y con qué SNI.
+type pipelineState struct {
+	Target string
+	Domain string // dominio de origen con --resolve
+	Port   int
+	TLS    bool
+	SNI    string
+	Proxy  string
+}
+
+// stageRunner es una etapa del pipeline. Run devuelve un error si el
+// objetivo no la supera y, si la supera, un resumen de lo obtenido.
+type stageRunner interface {
+	Run(st *pipelineState) (string, error)
+}
+
+type pipelineStage struct {
+	Label    string
+	Optional bool
+	runner   stageRunner
+}
+
+// pipeline ejecuta las etapas en orden sobre cada objetivo y cuenta cuántos
+// superan cada una.
+type pipeline struct {
+	Stages []*pipelineStage
+
+	mu     sync.Mutex
+	total  int
+	passed []int
+}
+
+// newPipeline construye las etapas configuradas.
+func newPipeline(configs []stageConfig, opts pipelineOptions) (*pipeline, error) {
+	if len(configs) == 0 {
+		return nil, errors.New("el pipeline no tiene etapas")
+	}
+	pl := &pipeline{passed: make([]int, len(configs))}
+	for i := range configs {
+		runner, err := newStageRunner(&configs[i], opts)
+		if err != nil {
+			return nil, err
+		}
+		pl.Stages = append(pl.Stages, &pipelineStage{
+			Label:    configs[i].Label(),
+			Optional: configs[i].Optional,
+			runner:   runner,
+		})
+	}
+	return pl, nil
+}
+
+func newStageRunner(c *stageConfig, opts pipelineOptions) (stageRunner, error) {
+	switch c.Name {
+	case "ping":
+		count := c.Count
+		if count <= 0 {
+			count = 1
+		}
+		return &pingStage{Count: count, Timeout: opts.Timeout}, nil
+	case "tcp":
+		if c.Port <= 0 || c.Port > 65535 {
+			return nil, fmt.Errorf("etapa tcp con puerto inválido: %d", c.Port)
+		}
+		return &tcpStage{Port: c.Port, Timeout: opts.Timeout}, nil
+	case "tls":
+		return &tlsStage{SNI: c.SNI, Timeout: opts.Timeout}, nil
+	case "http", "https":
+		filter := opts.Filter
+		if c.Match != "" || c.Filter != "" {
+			var err error
+			filter, err = (&matchFlags{Match: c.Match, Filter: c.Filter, BodyLimit: 64}).compile()
+			if err != nil {
+				return nil, fmt.Errorf("etapa %s: %w", c.Name, err)
+			}
+		}
+		if filter == nil {
+			filter = &responseFilter{}
+		}
+		stage := &httpStage{
+			Host:      c.Host,
+			Path:      c.Path,
+			Filter:    filter,
+			Redirects: opts.Redirects,
+			Timeout:   opts.Timeout,
+		}
+		if c.Name == "https" {
+			stage.Scheme = "https"
+		}
+		if stage.Path != "" && !strings.HasPrefix(stage.Path, "/") {
+			stage.Path = "/" + stage.Path
+		}
+		return stage, nil
+	case "banner":
+		nudge := c.Nudge
+		if nudge == "" {
+			nudge = banner.NudgeAuto
+		}
+		if !banner.ValidNudge(nudge) {
+			return nil, fmt.Errorf("etapa banner con nudge inválido: %s", nudge)
+		}
+		return &bannerStage{Nudge: nudge, Timeout: opts.Timeout}, nil
+	}
+	return nil, fmt.Errorf("etapa desconocida: %q (ping, tcp, tls, http, https o banner)", c.Name)
+}
+
+// pipelineResult es el recorrido de un objetivo por el pipeline.
+type pipelineResult struct {
+	Target string // objetivo tal como se indicó
+	State  *pipelineState
+	Steps  []string // resumen de cada etapa ejecutada
+	Passed bool     // superó todas las etapas obligatorias
+}
+
+func (r *pipelineResult) String() string {
+	s := r.Target + domainSuffix(r.Target) + " - " + strings.Join(r.Steps, " | ")
+	if r.State.Proxy != "" {
+		s += " (vía " + r.State.Proxy + ")"
+	}
+	return s
+}
+
+// Run pasa el objetivo por las etapas hasta que falla una obligatoria. Un
+// objetivo host:puerto empieza con ese puerto, como si hubiera superado una
+// etapa tcp.
+func (pl *pipeline) Run(target string) *pipelineResult {
+	st := &pipelineState{Target: target, Domain: resolvedDomain(target)}
+	if host, port, err := net.SplitHostPort(target); err == nil {
+		st.Target = host
+		st.Port, _ = strconv.Atoi(port)
+		if st.Domain == "" {
+			st.Domain = resolvedDomain(host)
+		}
+	}
+
+	res := &pipelineResult{Target: target, State: st, Passed: true}
+	passed := make([]bool, len(pl.Stages))
+	for i, stage := range pl.Stages {
+		info, err := stage.runner.Run(st)
+		if err != nil {
+			if !stage.Optional {
+				res.Passed = false
+				break
+			}
+			res.Steps = append(res.Steps, stage.Label+" -")
+			continue
+		}
+		passed[i] = true
+		step := stage.Label
+		if info != "" {
+			step += " " + info
+		}
+		res.Steps = append(res.Steps, step)
+	}
+
+	pl.mu.Lock()
+	pl.total++
+	for i, ok := range passed {
+		if ok {
+			pl.passed[i]++
+		}
+	}
+	pl.mu.Unlock()
+	return res
+}
+
+// Funnel da formato a cuántos objetivos superaron cada etapa.
+func (pl *pipeline) Funnel() string {
+	pl.mu.Lock()
+	defer pl.mu.Unlock()
+
+	width := len("objetivos")
+	for _, stage := range pl.Stages {
+		width = max(width, len(stage.Label))
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "  %-*s %6d\n", width, "objetivos", pl.total)
+	for i, stage := range pl.Stages {
+		pct := 0.0
+		if pl.total > 0 {
+			pct = float64(pl.passed[i]) / float64(pl.total) * 100
+		}
+		fmt.Fprintf(&b, "  %-*s %6d  %5.1f%%\n", width, stage.Label, pl.passed[i], pct)
+	}
+	return b.String()
+}
+
+// pingStage exige respuesta a ICMP.
+type pingStage struct {
+	Count   int
+	Timeout time.Duration
+}
+
+func (s *pingStage) Run(st *pipelineState) (string, error) {
+	pinger, err := ping.NewPinger(st.Target)
+	if err != nil {
+		return "", err
+	}
+	pinger.Count = s.Count
+	pinger.Timeout = s.Timeout
+	if err := pinger.Run(); err != nil {
+		return "", err
+	}
+	stats := pinger.Statistics()
+	if stats.PacketsRecv == 0 {
+		return "", errors.New("sin respuesta ICMP")
+	}
+	return stats.AvgRtt.Round(time.Millisecond).String(), nil
+}
+
+// pipelineAddr devuelve host:puerto del objetivo con el puerto de la etapa
+// anterior o, si no lo hay, el indicado.
+func pipelineAddr(st *pipelineState, port int) string {
+	if st.Port > 0 {
+		port = st.Port
+	}
+	return net.JoinHostPort(st.Target, strconv.Itoa(port))
+}
+
+// tcpStage exige que el puerto acepte conexiones y lo deja a las etapas
+// siguientes.
+type tcpStage struct {
+	Port    int
+	Timeout time.Duration
+}
+
+func (s *tcpStage) Run(st *pipelineState) (string, error) {
+	out := probeOutbound.pick()
+	ctx, cancel := context.WithTimeout(context.Background(), s.Timeout)
+	defer cancel()
+
+	start := time.Now()
+	conn, err := out.DialContext(ctx, "tcp", net.JoinHostPort(st.Target, strconv.Itoa(s.Port)))
+	if err != nil {
+		return "", err
+	}
+	conn.Close()
+
+	st.Port = s.Port
+	if proxy := out.Label(); proxy != "" {
+		st.Proxy = proxy
+	}
+	return time.Since(start).Round(time.Millisecond).String(), nil
+}
+
+// tlsStage exige un handshake TLS. El SNI es el indicado, el dominio de
+// --resolve o el propio objetivo si es un nombre.
+type tlsStage struct {
+	SNI     string
+	Timeout time.Duration
+}
+
+func (s *tlsStage) Run(st *pipelineState) (string, error) {
+	sni := s.SNI
+	if sni == "" {
+		sni = st.Domain
+	}
+	if sni == "" && net.ParseIP(st.Target) == nil {
+		sni = st.Target
+	}
+	config, err := newTLSConfig(sni, tlsVerifyNone)
+	if err != nil {
+		return "", err
+	}
+	config.NextProtos = []string{"h2", "http/1.1"}
+
+	out := probeOutbound.pick()
+	ctx, cancel := context.WithTimeout(context.Background(), s.Timeout)
+	defer cancel()
+	addr := pipelineAddr(st, 443)
+	conn, err := out.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return "", err
+	}
+	tlsConn := tls.Client(conn, config)
+	defer tlsConn.Close()
+	if err := tlsConn.HandshakeContext(ctx); err != nil {
+		return "", err
+	}
+
+	_, port, _ := net.SplitHostPort(addr)
+	st.Port, _ = strconv.Atoi(port)
+	st.TLS = true
+	st.SNI = sni
+	if proxy := out.Label(); proxy != "" {
+		st.Proxy = proxy
+	}
+
+	state := tlsConn.ConnectionState()
+	info := tls.VersionName(state.Version)
+	if state.NegotiatedProtocol != "" {
+		info += " " + state.NegotiatedProtocol
+	}
+	if len(state.PeerCertificates) > 0 {
+		if cn := state.PeerCertificates[0].Subject.CommonName; cn != "" {
+			info += " " + cn
+		}
+	}
+	return info, nil
+}
+
+// httpStage envía una solicitud HTTP y exige que la respuesta pase el
+// filtro. Usa HTTPS si una etapa tls anterior tuvo éxito.
+type httpStage struct {
+	Scheme    string // vacío elige según la etapa tls
+	Host      string
+	Path      string
+	Filter    *responseFilter
+	Redirects int
+	Timeout   time.Duration
+}
+
+func (s *httpStage) Run(st *pipelineState) (string, error) {
+	scheme := s.Scheme
+	if scheme == "" {
+		scheme = "http"
+		if st.TLS {
+			scheme = "https"
+		}
+	}
+	probe := &httpProbe{
+		Scheme:       scheme,
+		Port:         st.Port,
+		SNI:          st.SNI,
+		Host:         s.Host,
+		Path:         s.Path,
+		Proxy:        probeOutbound,
+		Timeout:      s.Timeout,
+		BodyLimit:    s.Filter.BodyLimit(),
+		MaxRedirects: s.Redirects,
+	}
+	res, err := probe.forDomain(st.Domain).Fetch(st.Target)
+	if err != nil {
+		return "", err
+	}
+	if !s.Filter.Keep(res) {
+		return "", errors.New("respuesta descartada por el filtro")
+	}
+	if res.Proxy != "" {
+		st.Proxy = res.Proxy
+	}
+
+	info := res.Status
+	if server := res.Server(); server != "" {
+		info += " " + server
+	}
+	if res.Provider != cdn.Unknown {
+		info += " [" + res.Provider + "]"
+	}
+	return info, nil
+}
+
+// bannerStage identifica el servicio del puerto de la etapa anterior.
+type bannerStage struct {
+	Nudge   string
+	Timeout time.Duration
+}
+
+func (s *bannerStage) Run(st *pipelineState) (string, error) {
+	if st.Port == 0 {
+		return "", errors.New("la etapa banner necesita un puerto (tcp:PUERTO o ip:puerto)")
+	}
+	probe := &bannerProbe{
+		Proxy:   probeOutbound,
+		Nudge:   s.Nudge,
+		Wait:    s.Timeout / 2,
+		Timeout: s.Timeout,
+	}
+	res, err := probe.Grab(pipelineAddr(st, 0))
+	if err != nil {
+		return "", err
+	}
+	if res.Proxy != "" {
+		st.Proxy = res.Proxy
+	}
+	if res.Version != "" {
+		return res.Service + " " + res.Version, nil
+	}
+	return res.Service, nil
+}
@@ -0,0 +1,260 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Pablo0303/Alama/pkg/proxydial"
+)
+
+// Estrategias de selección aceptadas por --proxy-rotation.
+const (
+	rotationRoundRobin   = "round-robin"
+	rotationRandom       = "random"
+	rotationLeastLatency = "least-latency"
+)
+
+// proxyMaxFails es el número de fallos seguidos al conectar con un proxy
+// tras el que se descarta.
+const proxyMaxFails = 3
+
+var errNoProxies = errors.New("no quedan proxies disponibles en --proxy-file")
+
+var (
+	globalFlagProxyFile          string
+	globalFlagProxyRotation      string
+	globalFlagProxyCheckURL      string
+	globalFlagProxyCheckInterval int
+)
+
+// poolProxy es un proxy del pool con sus estadísticas.
+type poolProxy struct {
+	pool *proxyPool
+	URL  *url.URL
+
+	// Protegidos por pool.mu
+	latency time.Duration // media móvil del tiempo de conexión
+	uses    int
+	fails   int // fallos seguidos
+	dead    bool
+}
+
+func (pp *poolProxy) String() string {
+	u := *pp.URL
+	if u.User != nil {
+		u.User = url.User(u.User.Username())
+	}
+	return u.String()
+}
+
+// DialContext conecta con el propio proxy y registra el resultado: es el
+// Dialer con el que se llega al proxy, no al destino.
+func (pp *poolProxy) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	start := time.Now()
+	conn, err := proxydial.Direct.DialContext(ctx, network, addr)
+	pp.pool.report(pp, time.Since(start), err)
+	return conn, err
+}
+
+// proxyPool reparte las conexiones entre varios proxies y descarta los que
+// dejan de responder.
+type proxyPool struct {
+	mu       sync.Mutex
+	proxies  []*poolProxy
+	rotation string
+	next     int
+	checkURL string
+}
+
+// loadProxyPool lee un proxy por línea (mismo formato que --proxy). Las
+// líneas vacías y las que empiezan por # se ignoran.
+func loadProxyPool(filename, rotation, checkURL string) (*proxyPool, error) {
+	switch rotation {
+	case rotationRoundRobin, rotationRandom, rotationLeastLatency:
+	default:
+		return nil, fmt.Errorf("estrategia de rotación inválida: %s", rotation)
+	}
+
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	pool := &proxyPool{rotation: rotation, checkURL: checkURL}
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		u, err := parseProxy(line)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", filename, n, err)
+		}
+		pool.proxies = append(pool.proxies, &poolProxy{pool: pool, URL: u})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(pool.proxies) == 0 {
+		return nil, fmt.Errorf("%s no contiene proxies", filename)
+	}
+
+	return pool, nil
+}
+
+// report registra el resultado de una conexión con el proxy. Tras
+// proxyMaxFails fallos seguidos el proxy se descarta.
+func (pool *proxyPool) report(pp *poolProxy, elapsed time.Duration, err error) {
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+
+	if err != nil {
+		pp.fails++
+		if pp.fails >= proxyMaxFails && !pp.dead {
+			pp.dead = true
+			fmt.Fprintf(os.Stderr, "\nProxy descartado: %s (%v)\n", pp, err)
+		}
+		return
+	}
+
+	pp.fails = 0
+	if pp.latency == 0 {
+		pp.latency = elapsed
+	} else {
+		pp.latency = (pp.latency*3 + elapsed) / 4
+	}
+}
+
+// pick elige un proxy vivo según la estrategia de rotación.
+func (pool *proxyPool) pick() (*poolProxy, error) {
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+
+	alive := make([]*poolProxy, 0, len(pool.proxies))
+	for _, pp := range pool.proxies {
+		if !pp.dead {
+			alive = append(alive, pp)
+		}
+	}
+	if len(alive) == 0 {
+		return nil, errNoProxies
+	}
+
+	var pp *poolProxy
+	switch pool.rotation {
+	case rotationRandom:
+		pp = alive[rand.Intn(len(alive))]
+	case rotationLeastLatency:
+		// Los proxies sin medir van primero para obtener su latencia.
+		for _, candidate := range alive {
+			if pp == nil || candidate.latency < pp.latency {
+				pp = candidate
+			}
+		}
+	default:
+		pp = alive[pool.next%len(alive)]
+		pool.next++
+	}
+	pp.uses++
+	return pp, nil
+}
+
+// checkProxy pide la URL de comprobación a través del proxy.
+func (pool *proxyPool) checkProxy(pp *poolProxy, timeout time.Duration) error {
+	tr := &http.Transport{DisableKeepAlives: true}
+	(&outbound{Proxy: pp.URL, member: pp}).configureTransport(tr)
+	client := &http.Client{Transport: tr, Timeout: timeout}
+
+	resp, err := client.Get(pool.checkURL)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 500 {
+		return fmt.Errorf("la URL de comprobación respondió %s", resp.Status)
+	}
+	return nil
+}
+
+// Check comprueba todos los proxies vivos a la vez y descarta los que fallan.
+// Devuelve cuántos siguen vivos.
+func (pool *proxyPool) Check(timeout time.Duration) int {
+	var wg sync.WaitGroup
+	for _, pp := range pool.proxies {
+		pool.mu.Lock()
+		dead := pp.dead
+		pool.mu.Unlock()
+		if dead {
+			continue
+		}
+
+		wg.Add(1)
+		go func(pp *poolProxy) {
+			defer wg.Done()
+			if err := pool.checkProxy(pp, timeout); err != nil {
+				pool.mu.Lock()
+				if !pp.dead {
+					pp.dead = true
+					fmt.Fprintf(os.Stderr, "\nProxy descartado: %s (%v)\n", pp, err)
+				}
+				pool.mu.Unlock()
+			}
+		}(pp)
+	}
+	wg.Wait()
+
+	return pool.Alive()
+}
+
+// Alive devuelve cuántos proxies siguen vivos.
+func (pool *proxyPool) Alive() int {
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+
+	n := 0
+	for _, pp := range pool.proxies {
+		if !pp.dead {
+			n++
+		}
+	}
+	return n
+}
+
+// watch repite la comprobación cada interval mientras dure el escaneo.
+func (pool *proxyPool) watch(interval, timeout time.Duration) {
+	go func() {
+		for range time.Tick(interval) {
+			if pool.Check(timeout) == 0 {
+				return
+			}
+		}
+	}()
+}
+
+// Summary resume el uso y el estado de cada proxy.
+func (pool *proxyPool) Summary() string {
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+
+	lines := make([]string, 0, len(pool.proxies))
+	for _, pp := range pool.proxies {
+		state := "vivo"
+		if pp.dead {
+			state = "descartado"
+		}
+		lines = append(lines, fmt.Sprintf("  %-40s %-10s usos: %-6d latencia: %dms", pp, state, pp.uses, pp.latency.Milliseconds()))
+	}
+	return strings.Join(lines, "\n")
+}
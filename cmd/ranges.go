@@ -0,0 +1,160 @@
+package cmd
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/Pablo0303/Alama/pkg/cdn"
+)
+
+// rangesCmd agrupa los subcomandos de la base de rangos de CDN
+var rangesCmd = &cobra.Command{
+	Use:   "ranges",
+	Short: "Consulta y actualiza los rangos de IP publicados por los CDN",
+	Long: `Alama incluye los prefijos publicados por los principales CDN. Un archivo
+local en ~/.Alama/ranges/<proveedor>.txt sustituye a los rangos incluidos;
+"Alama ranges update" lo crea a partir de un archivo descargado.`,
+}
+
+var rangesListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "Lista los proveedores con rangos conocidos",
+	Args:  cobra.NoArgs,
+	Run:   runRangesList,
+}
+
+var rangesShowCmd = &cobra.Command{
+	Use:   "show <proveedor>",
+	Short: "Muestra los rangos de un proveedor",
+	Args:  cobra.ExactArgs(1),
+	Run:   runRangesShow,
+}
+
+var rangesExportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Exporta los rangos de uno o varios proveedores, un CIDR por línea",
+	Args:  cobra.NoArgs,
+	Run:   runRangesExport,
+}
+
+var rangesUpdateCmd = &cobra.Command{
+	Use:   "update <proveedor>",
+	Short: "Sustituye los rangos de un proveedor por los de un archivo local",
+	Args:  cobra.ExactArgs(1),
+	Run:   runRangesUpdate,
+}
+
+var (
+	rangesFlagProvider string
+	rangesFlagV4       bool
+	rangesFlagV6       bool
+	rangesFlagOutput   string
+	rangesFlagFile     string
+)
+
+func init() {
+	rootCmd.AddCommand(rangesCmd)
+	rangesCmd.AddCommand(rangesListCmd, rangesShowCmd, rangesExportCmd, rangesUpdateCmd)
+
+	rangesExportCmd.Flags().StringVar(&rangesFlagProvider, "provider", "", "Proveedores a exportar, separados por comas")
+	rangesExportCmd.Flags().BoolVar(&rangesFlagV4, "v4", false, "Solo rangos IPv4")
+	rangesExportCmd.Flags().BoolVar(&rangesFlagV6, "v6", false, "Solo rangos IPv6")
+	rangesExportCmd.Flags().StringVarP(&rangesFlagOutput, "output", "o", "", "Archivo de salida (por defecto la consola)")
+	rangesExportCmd.MarkFlagRequired("provider")
+
+	rangesUpdateCmd.Flags().StringVarP(&rangesFlagFile, "file", "f", "", "Archivo con un CIDR por línea")
+	rangesUpdateCmd.MarkFlagFilename("file")
+	rangesUpdateCmd.MarkFlagRequired("file")
+}
+
+func runRangesList(cmd *cobra.Command, args []string) {
+	providers, err := cdn.Providers()
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	for _, provider := range providers {
+		set, _ := cdn.Ranges(provider)
+		fmt.Printf("%-12s IPv4: %-4d IPv6: %-4d %s\n", provider, len(set.V4()), len(set.V6()), set.Source)
+	}
+}
+
+func runRangesShow(cmd *cobra.Command, args []string) {
+	set, err := cdn.Ranges(args[0])
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("%s (%s)\n", set.Provider, colorB1.Sprint(set.Source))
+	for _, ipnet := range set.Nets {
+		fmt.Println(ipnet)
+	}
+}
+
+func runRangesExport(cmd *cobra.Command, args []string) {
+	nets, err := providerNets(rangesFlagProvider, rangesFlagV4, rangesFlagV6)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	lines := make([]string, 0, len(nets))
+	for _, ipnet := range nets {
+		lines = append(lines, ipnet.String())
+	}
+
+	if rangesFlagOutput == "" {
+		fmt.Println(strings.Join(lines, "\n"))
+		return
+	}
+	writeResults(rangesFlagOutput, lines)
+}
+
+func runRangesUpdate(cmd *cobra.Command, args []string) {
+	f, err := os.Open(rangesFlagFile)
+	if err != nil {
+		fmt.Println("Error al abrir el archivo:", err)
+		os.Exit(1)
+	}
+	defer f.Close()
+
+	path, n, err := cdn.UpdateRanges(args[0], f)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	fmt.Printf("%d rangos guardados en %s\n", n, path)
+}
+
+// providerNets devuelve los rangos de los proveedores indicados (separados por
+// comas). Si v4 o v6 está activo se devuelven solo los de esa familia.
+func providerNets(providers string, v4, v6 bool) ([]*net.IPNet, error) {
+	var nets []*net.IPNet
+
+	for _, provider := range strings.Split(providers, ",") {
+		provider = strings.TrimSpace(provider)
+		if provider == "" {
+			continue
+		}
+		set, err := cdn.Ranges(provider)
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case v4 && !v6:
+			nets = append(nets, set.V4()...)
+		case v6 && !v4:
+			nets = append(nets, set.V6()...)
+		default:
+			nets = append(nets, set.Nets...)
+		}
+	}
+
+	return nets, nil
+}
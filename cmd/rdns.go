@@ -0,0 +1,125 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// rdnsTimeout es el tiempo de espera de cada consulta PTR.
+const rdnsTimeout = 3 * time.Second
+
+// rdnsFlags agrupa las banderas de resolución inversa.
+type rdnsFlags struct {
+	Enabled bool
+	Match   string
+	Threads int
+}
+
+// addRDNSFlags registra las banderas de resolución inversa en el comando.
+func addRDNSFlags(cmd *cobra.Command, f *rdnsFlags) {
+	cmd.Flags().BoolVar(&f.Enabled, "rdns", false, "Resuelve el nombre PTR de cada resultado")
+	cmd.Flags().StringVar(&f.Match, "rdns-match", "", "Muestra solo los resultados con un PTR que coincide con el patrón (ej. '*.fastly.net'); implica --rdns")
+	cmd.Flags().IntVar(&f.Threads, "rdns-threads", 20, "Número de consultas PTR concurrentes")
+}
+
+// rdnsResolver resuelve nombres PTR con caché y un límite de consultas
+// concurrentes propio, independiente de los hilos del escaneo.
+type rdnsResolver struct {
+	patterns []string
+	sem      chan struct{}
+
+	mu    sync.Mutex
+	cache map[string]*rdnsEntry
+}
+
+// rdnsEntry es una consulta en curso o terminada; done se cierra al
+// terminar.
+type rdnsEntry struct {
+	done  chan struct{}
+	names []string
+}
+
+// compile devuelve el resolvedor, o nil si no se pidió --rdns.
+func (f *rdnsFlags) compile() (*rdnsResolver, error) {
+	if !f.Enabled && f.Match == "" {
+		return nil, nil
+	}
+	if f.Threads < 1 {
+		return nil, fmt.Errorf("--rdns-threads debe ser mayor que 0")
+	}
+
+	r := &rdnsResolver{
+		sem:   make(chan struct{}, f.Threads),
+		cache: make(map[string]*rdnsEntry),
+	}
+	for _, pattern := range splitList(f.Match) {
+		pattern = strings.ToLower(strings.TrimSuffix(pattern, "."))
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("--rdns-match: %w", err)
+		}
+		r.patterns = append(r.patterns, pattern)
+	}
+	return r, nil
+}
+
+// Lookup devuelve los nombres PTR de ip, sin el punto final. Las consultas
+// repetidas se sirven de la caché.
+func (r *rdnsResolver) Lookup(ip string) []string {
+	r.mu.Lock()
+	entry, ok := r.cache[ip]
+	if !ok {
+		entry = &rdnsEntry{done: make(chan struct{})}
+		r.cache[ip] = entry
+	}
+	r.mu.Unlock()
+
+	if ok {
+		<-entry.done
+		return entry.names
+	}
+
+	r.sem <- struct{}{}
+	ctx, cancel := context.WithTimeout(context.Background(), rdnsTimeout)
+	names, _ := net.DefaultResolver.LookupAddr(ctx, ip)
+	cancel()
+	<-r.sem
+
+	for i, name := range names {
+		names[i] = strings.TrimSuffix(name, ".")
+	}
+	entry.names = names
+	close(entry.done)
+	return names
+}
+
+// Keep indica si un resultado con estos nombres debe mostrarse. Sin
+// --rdns-match se muestran todos.
+func (r *rdnsResolver) Keep(names []string) bool {
+	if len(r.patterns) == 0 {
+		return true
+	}
+	for _, name := range names {
+		name = strings.ToLower(name)
+		for _, pattern := range r.patterns {
+			if ok, _ := path.Match(pattern, name); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// rdnsSuffix da formato a los nombres PTR para añadirlos a un resultado.
+func rdnsSuffix(names []string) string {
+	if len(names) == 0 {
+		return ""
+	}
+	return " - ptr " + strings.Join(names, ",")
+}
@@ -0,0 +1,257 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/Pablo0303/Alama/pkg/queuescanner"
+	"github.com/Pablo0303/Alama/pkg/resolve"
+)
+
+var resolveCmd = &cobra.Command{
+	Use:   "resolve [dominio...]",
+	Short: "Resuelve dominios a sus IPs (A/AAAA) y su CNAME",
+	Long: `Resuelve de forma concurrente los registros A, AAAA y CNAME de cada dominio y
+muestra la correspondencia dominio -> IPs. Los fallos se clasifican como
+nxdomain, servfail, timeout o error y se resumen al terminar.
+
+Con -o se guarda una línea por dominio resuelto; con --ips-only, una IP por
+línea, lista para -f en los comandos de escaneo.`,
+	Run: runResolve,
+}
+
+var (
+	resolveFlagFile    string
+	resolveFlagOutput  string
+	resolveFlagFailed  string
+	resolveFlagIPsOnly bool
+	resolveFlagTimeout int
+	resolveFlagThreads int
+)
+
+// resolveTimeout es el tiempo de espera de cada resolución de --resolve.
+const resolveTimeout = 5 * time.Second
+
+// targetDomains guarda, para cada objetivo obtenido con --resolve, los
+// dominios de los que procede. Se rellena antes de empezar el escaneo y
+// después solo se lee.
+var targetDomains = map[string][]string{}
+
+func init() {
+	rootCmd.AddCommand(resolveCmd)
+
+	resolveCmd.Flags().StringVarP(&resolveFlagFile, "file", "f", "", "Archivo que contiene la lista de dominios")
+	resolveCmd.Flags().StringVarP(&resolveFlagOutput, "output", "o", "", "Archivo de salida para guardar los resultados")
+	resolveCmd.Flags().StringVar(&resolveFlagFailed, "failed", "", "Archivo en el que guardar los dominios no resueltos y el motivo")
+	resolveCmd.Flags().BoolVar(&resolveFlagIPsOnly, "ips-only", false, "Guarda solo las IPs, sin repetir, una por línea")
+	resolveCmd.Flags().IntVarP(&resolveFlagTimeout, "timeout", "t", 5, "Tiempo de espera de cada resolución en segundos")
+	resolveCmd.Flags().IntVarP(&resolveFlagThreads, "threads", "T", 50, "Número de hilos concurrentes")
+}
+
+// addResolveFlag registra --resolve en el comando.
+func addResolveFlag(cmd *cobra.Command, p *bool) {
+	cmd.Flags().BoolVar(p, "resolve", false, "Resuelve los dominios de la lista y escanea todas sus IPs")
+}
+
+// resolvedString da formato a una resolución correcta.
+func resolvedString(res *resolve.Result) string {
+	ips := make([]string, 0, len(res.IPs))
+	for _, ip := range res.IPs {
+		ips = append(ips, ip.String())
+	}
+	s := res.Domain
+	if res.CNAME != "" {
+		s += " (cname " + res.CNAME + ")"
+	}
+	return s + " -> " + strings.Join(ips, ", ")
+}
+
+// resolveSummary da formato al recuento de resoluciones por estado.
+func resolveSummary(counts map[resolve.Status]int) string {
+	parts := make([]string, 0, len(resolve.Statuses))
+	for _, status := range resolve.Statuses {
+		if counts[status] > 0 {
+			parts = append(parts, fmt.Sprintf("%s=%d", status, counts[status]))
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// resolveTargets sustituye los dominios de targets (también en forma
+// dominio:puerto) por todas sus IPs y anota su origen en targetDomains. Los
+// objetivos que ya son IPs se mantienen.
+func resolveTargets(targets []string, threads int) []string {
+	resolver := resolve.New(resolveTimeout)
+	results := make([][]string, len(targets))
+	counts := make(map[resolve.Status]int)
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, threads)
+	for i, target := range targets {
+		host, port, err := net.SplitHostPort(target)
+		if err != nil {
+			host, port = target, ""
+		}
+		if !resolve.IsDomain(host) {
+			results[i] = []string{target}
+			continue
+		}
+
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, host, port string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			res := resolver.Resolve(context.Background(), host)
+
+			mu.Lock()
+			defer mu.Unlock()
+			counts[res.Status]++
+			for _, ip := range res.IPs {
+				addr := ip.String()
+				if port != "" {
+					addr = net.JoinHostPort(addr, port)
+				}
+				if _, seen := targetDomains[addr]; !seen {
+					results[i] = append(results[i], addr)
+				}
+				targetDomains[addr] = append(targetDomains[addr], host)
+			}
+		}(i, host, port)
+	}
+	wg.Wait()
+
+	if len(counts) > 0 {
+		fmt.Fprintf(os.Stderr, "Resolución: %s\n", resolveSummary(counts))
+	}
+
+	expanded := make([]string, 0, len(targets))
+	for _, addrs := range results {
+		expanded = append(expanded, addrs...)
+	}
+	return expanded
+}
+
+// resolvedDomain devuelve el primer dominio del que procede el objetivo, o
+// una cadena vacía si no se obtuvo con --resolve.
+func resolvedDomain(target string) string {
+	if domains := targetDomains[target]; len(domains) > 0 {
+		return domains[0]
+	}
+	return ""
+}
+
+// domainSuffix da formato a los dominios de un objetivo obtenido con
+// --resolve para añadirlos a un resultado.
+func domainSuffix(target string) string {
+	domains := targetDomains[target]
+	if len(domains) == 0 {
+		return ""
+	}
+	return " (" + strings.Join(domains, ",") + ")"
+}
+
+// loadDomains lee los dominios de los argumentos y del archivo.
+func loadDomains(args []string, filename string) ([]string, error) {
+	domains := append([]string(nil), args...)
+	if filename == "" {
+		return domains, nil
+	}
+
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, fmt.Errorf("error al abrir el archivo: %w", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		domains = append(domains, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error al leer el archivo: %w", err)
+	}
+	return domains, nil
+}
+
+func scanResolve(c *queuescanner.Ctx, p *queuescanner.QueueScannerScanParams) {
+	resolver := p.Data.(*resolve.Resolver)
+
+	res := resolver.Resolve(context.Background(), p.Name)
+	if res.Status != resolve.OK {
+		c.ScanError(res, res.Err, func() {
+			c.Log(colorY1.Sprintf("%s - %s", res.Domain, res.Status))
+		})
+		return
+	}
+
+	c.ScanSuccess(res, func() {
+		c.Log(colorG1.Sprint(resolvedString(res)))
+	})
+}
+
+func runResolve(cmd *cobra.Command, args []string) {
+	domains, err := loadDomains(args, resolveFlagFile)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	if len(domains) == 0 {
+		fmt.Println("Indica los dominios como argumentos o con -f")
+		os.Exit(1)
+	}
+
+	resolver := resolve.New(time.Duration(resolveFlagTimeout) * time.Second)
+	queueScanner := queuescanner.NewQueueScanner(resolveFlagThreads, scanResolve)
+	for _, domain := range domains {
+		queueScanner.Add(&queuescanner.QueueScannerScanParams{
+			Name: domain,
+			Data: resolver,
+		})
+	}
+
+	queueScanner.Start(func(c *queuescanner.Ctx) {
+		counts := map[resolve.Status]int{resolve.OK: len(c.ScanSuccessList)}
+
+		results := make([]string, 0, len(c.ScanSuccessList))
+		seen := make(map[string]bool)
+		for _, r := range c.ScanSuccessList {
+			res := r.(*resolve.Result)
+			if !resolveFlagIPsOnly {
+				results = append(results, resolvedString(res))
+				continue
+			}
+			for _, ip := range res.IPs {
+				if !seen[ip.String()] {
+					seen[ip.String()] = true
+					results = append(results, ip.String())
+				}
+			}
+		}
+		writeResults(resolveFlagOutput, results)
+
+		failed := make([]string, 0, len(c.ScanFailedList))
+		for _, r := range c.ScanFailedList {
+			res := r.(*resolve.Result)
+			counts[res.Status]++
+			failed = append(failed, fmt.Sprintf("%s - %s", res.Domain, res.Status))
+		}
+		writeResults(resolveFlagFailed, failed)
+
+		fmt.Printf("\nResolución: %s\n", resolveSummary(counts))
+	})
+}
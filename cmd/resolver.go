@@ -0,0 +1,45 @@
+package cmd
+
+import (
+	"fmt"
+	"net"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/Pablo0303/Alama/pkg/resolve"
+)
+
+var globalFlagResolver []string
+
+// dnsClient reparte las consultas DNS entre los servidores de --resolver,
+// o es nil si se usa el resolvedor del sistema.
+var dnsClient *resolve.Client
+
+// initResolver sustituye el resolvedor del sistema por los servidores de
+// --resolver. Como todas las sondas (net.Dialer, ping, net/http, QUIC)
+// usan net.DefaultResolver, todas comparten los servidores y la caché.
+func initResolver() {
+	if len(globalFlagResolver) == 0 {
+		return
+	}
+
+	upstreams := make([]resolve.Upstream, 0, len(globalFlagResolver))
+	for _, s := range globalFlagResolver {
+		upstream, err := resolve.ParseUpstream(s)
+		cobra.CheckErr(err)
+		upstreams = append(upstreams, upstream)
+	}
+
+	dnsClient = resolve.NewClient(upstreams)
+	net.DefaultResolver = dnsClient.Resolver()
+}
+
+// resolverSummary muestra el uso de la caché DNS al terminar.
+func resolverSummary() {
+	if dnsClient == nil {
+		return
+	}
+	hits, misses := dnsClient.Cache.Stats()
+	fmt.Fprintf(os.Stderr, "\nCaché DNS: %d aciertos, %d consultas a los resolvedores\n", hits, misses)
+}
@@ -0,0 +1,507 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+var runCmd = &cobra.Command{
+	Use:   "run trabajo.yaml",
+	Short: "Ejecuta un trabajo de varios pasos descrito en un archivo YAML",
+	Long: `Ejecuta en orden los pasos del trabajo. Cada paso es un comando de Alama con
+sus banderas; sus resultados se guardan en el directorio de salida y un paso
+posterior puede tomarlos como objetivos con input.
+
+  name: cloudflare
+  output: resultados        # directorio; por defecto <trabajo>-resultados
+  flags:                    # banderas comunes a los pasos que las tengan
+    threads: 100
+  steps:
+    - name: vivos
+      command: direct
+      flags:
+        cidr: 104.16.0.0/24
+    - name: sni
+      command: scan sni
+      flags:
+        filename: dominios.lst
+    - name: http
+      command: httping
+      input: vivos          # los objetivos son los resultados de vivos
+      when: vivos.results > 0
+      on_error: continue    # stop (por defecto) detiene el trabajo
+      flags:
+        status: 2xx
+
+when admite <paso>.results o <paso>.exit comparados con un número (>, >=, <,
+<=, ==, !=). Si no se cumple, el paso se omite, al igual que los que toman
+como entrada un paso omitido.
+
+Al terminar se muestra un informe con el estado y los resultados de cada paso;
+con report: informe.txt se guarda además, con los resultados de todos los
+pasos.`,
+	Args: cobra.ExactArgs(1),
+	// Cada paso prepara sus propios proxies y DNS.
+	PersistentPreRun: func(*cobra.Command, []string) {},
+	Run:              runJob,
+}
+
+var runFlagDryRun bool
+
+func init() {
+	rootCmd.AddCommand(runCmd)
+
+	runCmd.Flags().BoolVar(&runFlagDryRun, "dry-run", false, "Muestra los comandos de cada paso sin ejecutarlos")
+}
+
+// job es un trabajo de run.
+type job struct {
+	Name   string                 `mapstructure:"name"`
+	Output string                 `mapstructure:"output"`
+	Report string                 `mapstructure:"report"`
+	Flags  map[string]interface{} `mapstructure:"flags"`
+	Steps  []*jobStep             `mapstructure:"steps"`
+}
+
+// jobStep es un paso del trabajo.
+type jobStep struct {
+	Name    string                 `mapstructure:"name"`
+	Command string                 `mapstructure:"command"`
+	Args    []string               `mapstructure:"args"`
+	Flags   map[string]interface{} `mapstructure:"flags"`
+	Input   string                 `mapstructure:"input"`
+	Output  string                 `mapstructure:"output"`
+	When    string                 `mapstructure:"when"`
+	OnError string                 `mapstructure:"on_error"`
+
+	cmd       *cobra.Command
+	inputFlag string
+	when      *jobCondition
+}
+
+// Estados de un paso en el informe.
+const (
+	stepOK      = "ok"
+	stepFailed  = "error"
+	stepSkipped = "omitido"
+)
+
+// jobStepResult es lo que dejó un paso.
+type jobStepResult struct {
+	Status   string
+	Exit     int
+	Results  []string
+	Duration time.Duration
+	Output   string
+	Targets  string // objetivos extraídos de la salida, para input
+	Reason   string
+}
+
+// jobCondition es una condición de when: <paso>.<métrica> <op> <número>.
+type jobCondition struct {
+	Step   string
+	Metric string
+	Op     string
+	Value  int
+}
+
+var jobConditionRe = regexp.MustCompile(`^\s*([\w.-]+)\.(results|exit)\s*(>=|<=|==|!=|>|<)\s*(-?\d+)\s*$`)
+
+// parseJobCondition interpreta la condición de when.
+func parseJobCondition(s string) (*jobCondition, error) {
+	m := jobConditionRe.FindStringSubmatch(s)
+	if m == nil {
+		return nil, fmt.Errorf("condición inválida: %q (ej. vivos.results > 0)", s)
+	}
+	value, _ := strconv.Atoi(m[4])
+	return &jobCondition{Step: m[1], Metric: m[2], Op: m[3], Value: value}, nil
+}
+
+// Eval evalúa la condición con el resultado de su paso.
+func (c *jobCondition) Eval(res *jobStepResult) bool {
+	v := len(res.Results)
+	if c.Metric == "exit" {
+		v = res.Exit
+	}
+	switch c.Op {
+	case ">":
+		return v > c.Value
+	case ">=":
+		return v >= c.Value
+	case "<":
+		return v < c.Value
+	case "<=":
+		return v <= c.Value
+	case "==":
+		return v == c.Value
+	}
+	return v != c.Value
+}
+
+func (c *jobCondition) String() string {
+	return fmt.Sprintf("%s.%s %s %d", c.Step, c.Metric, c.Op, c.Value)
+}
+
+// loadJob lee y valida el trabajo.
+func loadJob(filename string) (*job, error) {
+	v := viper.New()
+	v.SetConfigFile(filename)
+	if err := v.ReadInConfig(); err != nil {
+		return nil, err
+	}
+	j := &job{}
+	if err := v.Unmarshal(j); err != nil {
+		return nil, fmt.Errorf("%s: %w", filename, err)
+	}
+	if j.Name == "" {
+		j.Name = strings.TrimSuffix(filepath.Base(filename), filepath.Ext(filename))
+	}
+	if j.Output == "" {
+		j.Output = j.Name + "-resultados"
+	}
+	if err := j.validate(); err != nil {
+		return nil, fmt.Errorf("%s: %w", filename, err)
+	}
+	return j, nil
+}
+
+// validate comprueba los pasos antes de ejecutar ninguno: comandos, banderas,
+// entradas y condiciones.
+func (j *job) validate() error {
+	if len(j.Steps) == 0 {
+		return fmt.Errorf("el trabajo no tiene pasos")
+	}
+
+	seen := make(map[string]bool)
+	for i, step := range j.Steps {
+		if step.Name == "" {
+			step.Name = "paso" + strconv.Itoa(i+1)
+		}
+		if seen[step.Name] {
+			return fmt.Errorf("paso repetido: %s", step.Name)
+		}
+
+		cmd, rest, err := rootCmd.Find(strings.Fields(step.Command))
+		if err != nil || len(rest) > 0 || cmd == rootCmd || cmd.Name() == "run" || !cmd.Runnable() {
+			return fmt.Errorf("%s: comando desconocido: %q", step.Name, step.Command)
+		}
+		step.cmd = cmd
+		cmd.InheritedFlags()
+
+		for name, value := range step.Flags {
+			if err := checkJobFlag(cmd, name, value); err != nil {
+				return fmt.Errorf("%s: %w", step.Name, err)
+			}
+		}
+		if cmd.Flags().Lookup("output") == nil {
+			return fmt.Errorf("%s: %s no guarda resultados con --output", step.Name, step.Command)
+		}
+		if _, ok := step.Flags["output"]; ok {
+			return fmt.Errorf("%s: la salida se indica con output en el paso, no en flags", step.Name)
+		}
+
+		if step.Input != "" {
+			if !seen[step.Input] {
+				return fmt.Errorf("%s: input debe ser un paso anterior: %s", step.Name, step.Input)
+			}
+			if step.inputFlag = jobInputFlag(cmd); step.inputFlag == "" {
+				return fmt.Errorf("%s: %s no lee objetivos de un archivo", step.Name, step.Command)
+			}
+			if _, ok := step.Flags[step.inputFlag]; ok {
+				return fmt.Errorf("%s: input y --%s no se pueden combinar", step.Name, step.inputFlag)
+			}
+		}
+
+		if step.When != "" {
+			if step.when, err = parseJobCondition(step.When); err != nil {
+				return fmt.Errorf("%s: %w", step.Name, err)
+			}
+			if !seen[step.when.Step] {
+				return fmt.Errorf("%s: when debe referirse a un paso anterior: %s", step.Name, step.when.Step)
+			}
+		}
+
+		switch step.OnError {
+		case "":
+			step.OnError = "stop"
+		case "stop", "continue":
+		default:
+			return fmt.Errorf("%s: on_error debe ser stop o continue: %s", step.Name, step.OnError)
+		}
+		seen[step.Name] = true
+	}
+
+	for name, value := range j.Flags {
+		used := false
+		for _, step := range j.Steps {
+			if step.cmd.Flags().Lookup(name) != nil {
+				if err := checkJobFlag(step.cmd, name, value); err != nil {
+					return err
+				}
+				used = true
+			}
+		}
+		if !used {
+			return fmt.Errorf("ningún paso tiene la bandera --%s", name)
+		}
+	}
+	return nil
+}
+
+// jobInputFlag devuelve la bandera con la que el comando lee los objetivos
+// de un archivo, o "" si no tiene.
+func jobInputFlag(cmd *cobra.Command) string {
+	for _, name := range []string{"file", "filename"} {
+		if cmd.Flags().Lookup(name) != nil {
+			return name
+		}
+	}
+	return ""
+}
+
+// checkJobFlag comprueba que el comando tiene la bandera y acepta el valor.
+func checkJobFlag(cmd *cobra.Command, name string, value interface{}) error {
+	f := cmd.Flags().Lookup(name)
+	if f == nil || configSkip[name] {
+		return fmt.Errorf("%s no tiene la bandera --%s", cmd.CommandPath(), name)
+	}
+	s, ok := configString(value)
+	if !ok {
+		return fmt.Errorf("--%s: se esperaba un valor", name)
+	}
+	return checkFlagValue(f, s)
+}
+
+// jobFlag da formato a una bandera para la línea de comandos del paso.
+func jobFlag(name string, value interface{}) string {
+	s, _ := configString(value)
+	return "--" + name + "=" + s
+}
+
+// args devuelve la línea de comandos del paso: las banderas globales de run,
+// las comunes del trabajo, las del paso, la entrada y la salida.
+func (step *jobStep) args(j *job, global []string, input, output string) []string {
+	args := append(strings.Fields(step.Command), global...)
+
+	names := make([]string, 0, len(j.Flags))
+	for name := range j.Flags {
+		if _, ok := step.Flags[name]; !ok && step.cmd.Flags().Lookup(name) != nil {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		args = append(args, jobFlag(name, j.Flags[name]))
+	}
+
+	names = names[:0]
+	for name := range step.Flags {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		args = append(args, jobFlag(name, step.Flags[name]))
+	}
+
+	if input != "" {
+		args = append(args, "--"+step.inputFlag+"="+input)
+	}
+	args = append(args, "--output="+output)
+	return append(args, step.Args...)
+}
+
+// globalJobArgs devuelve las banderas globales indicadas al llamar a run,
+// que se pasan a todos los pasos.
+func globalJobArgs() []string {
+	var args []string
+	rootCmd.PersistentFlags().VisitAll(func(f *pflag.Flag) {
+		if !f.Changed || f.Name == "help" {
+			return
+		}
+		value := f.Value.String()
+		if sv, ok := f.Value.(pflag.SliceValue); ok {
+			value = strings.Join(sv.GetSlice(), ",")
+		}
+		args = append(args, "--"+f.Name+"="+value)
+	})
+	return args
+}
+
+// readJobResults lee los resultados que dejó un paso, uno por línea.
+func readJobResults(filename string) []string {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil
+	}
+	defer file.Close()
+
+	var results []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); line != "" {
+			results = append(results, line)
+		}
+	}
+	return results
+}
+
+// writeJobTargets guarda el primer campo de cada resultado (la IP, el host o
+// el dominio) para usarlo como entrada de otro paso.
+func writeJobTargets(filename string, results []string) error {
+	seen := make(map[string]bool)
+	targets := make([]string, 0, len(results))
+	for _, res := range results {
+		target := strings.Fields(res)[0]
+		if !seen[target] {
+			seen[target] = true
+			targets = append(targets, target)
+		}
+	}
+	return os.WriteFile(filename, []byte(strings.Join(targets, "\n")), 0644)
+}
+
+func runJob(cmd *cobra.Command, args []string) {
+	j, err := loadJob(args[0])
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	if !runFlagDryRun {
+		if err := os.MkdirAll(j.Output, 0755); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	}
+
+	global := globalJobArgs()
+	results := make(map[string]*jobStepResult)
+	failed := false
+	for _, step := range j.Steps {
+		res := &jobStepResult{Status: stepSkipped}
+		results[step.Name] = res
+
+		if failed {
+			res.Reason = "trabajo detenido"
+			continue
+		}
+		if step.when != nil && !runFlagDryRun && !step.when.Eval(results[step.when.Step]) {
+			res.Reason = "no se cumple " + step.when.String()
+			continue
+		}
+		input := ""
+		if step.Input != "" {
+			from := results[step.Input]
+			if from.Status == stepSkipped && !runFlagDryRun {
+				res.Reason = "se omitió " + step.Input
+				continue
+			}
+			input = from.Targets
+		}
+
+		res.Output = step.Output
+		if res.Output == "" {
+			res.Output = filepath.Join(j.Output, step.Name+".lst")
+		}
+		res.Targets = filepath.Join(j.Output, step.Name+".objetivos")
+
+		stepArgs := step.args(j, global, input, res.Output)
+		fmt.Fprintf(os.Stderr, "\n== %s: Alama %s\n", step.Name, strings.Join(stepArgs, " "))
+		if runFlagDryRun {
+			res.Status = stepOK
+			continue
+		}
+
+		os.Remove(res.Output)
+		start := time.Now()
+		c := exec.Command(exe, stepArgs...)
+		c.Stdin, c.Stdout, c.Stderr = os.Stdin, os.Stdout, os.Stderr
+		err := c.Run()
+		res.Duration = time.Since(start)
+		res.Results = readJobResults(res.Output)
+		if err := writeJobTargets(res.Targets, res.Results); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+		}
+
+		res.Status = stepOK
+		if err != nil {
+			res.Status = stepFailed
+			res.Exit = -1
+			if exitErr, ok := err.(*exec.ExitError); ok {
+				res.Exit = exitErr.ExitCode()
+			}
+			res.Reason = err.Error()
+			failed = step.OnError == "stop"
+		}
+	}
+
+	if runFlagDryRun {
+		return
+	}
+	report := jobReport(j, results)
+	fmt.Printf("\nInforme de %s:\n%s", j.Name, report)
+	if j.Report != "" {
+		if err := writeJobReport(j, results, report); err != nil {
+			fmt.Println("Error al escribir el informe:", err)
+		}
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
+
+// jobReport da formato al resumen de los pasos.
+func jobReport(j *job, results map[string]*jobStepResult) string {
+	var b strings.Builder
+	w := tabwriter.NewWriter(&b, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "  paso\tcomando\testado\tresultados\tduración\tsalida")
+	for _, step := range j.Steps {
+		res := results[step.Name]
+		status := res.Status
+		if res.Reason != "" {
+			status += " (" + res.Reason + ")"
+		}
+		output := res.Output
+		if res.Status == stepSkipped {
+			output = "-"
+		}
+		fmt.Fprintf(w, "  %s\t%s\t%s\t%d\t%s\t%s\n", step.Name, step.Command, status,
+			len(res.Results), res.Duration.Round(time.Second), output)
+	}
+	w.Flush()
+	return b.String()
+}
+
+// writeJobReport guarda el resumen y los resultados de todos los pasos.
+func writeJobReport(j *job, results map[string]*jobStepResult, report string) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Informe de %s (%s)\n\n%s", j.Name, time.Now().Format(time.RFC3339), report)
+	for _, step := range j.Steps {
+		res := results[step.Name]
+		if res.Status == stepSkipped {
+			continue
+		}
+		fmt.Fprintf(&b, "\n## %s (%d)\n", step.Name, len(res.Results))
+		for _, line := range res.Results {
+			b.WriteString(line + "\n")
+		}
+	}
+	return os.WriteFile(j.Report, []byte(b.String()), 0644)
+}
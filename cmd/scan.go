@@ -1,9 +1,7 @@
 package cmd
 
 import (
-    "bufio"
     "fmt"
-    "net"
     "os"
     "strings"
     "sync"
@@ -12,6 +10,8 @@ import (
     "github.com/go-ping/ping"
     "github.com/spf13/cobra"
     "github.com/fatih/color"
+
+    "github.com/Pablo0303/Alama/pkg/queuescanner"
 )
 
 // scanCmd represents the scan command
@@ -28,18 +28,20 @@ Opciones:
   -d, --delay int         Retraso entre escaneos en milisegundos (por defecto 250)
   -n, --count int         Número de intentos de escaneo por IP (por defecto 1)
   -T, --threads int       Número de hilos concurrentes (por defecto 50)
+      --provider-ranges   Proveedores cuyos rangos publicados se escanean (ej. cloudflare)
 `,
     Run: scanRun,
 }
 
 var (
-    scanFlagCIDR    string
-    scanFlagFile    string
-    scanFlagOutput  string
-    scanFlagTimeout int
-    scanFlagDelay   int
-    scanFlagCount   int
-    scanFlagThreads int
+    scanFlagCIDR           string
+    scanFlagFile           string
+    scanFlagOutput         string
+    scanFlagTimeout        int
+    scanFlagDelay          int
+    scanFlagCount          int
+    scanFlagThreads        int
+    scanFlagProviderRanges string
 )
 
 func init() {
@@ -52,6 +54,7 @@ func init() {
     scanCmd.Flags().IntVarP(&scanFlagDelay, "delay", "d", 250, "Retraso entre escaneos en milisegundos")
     scanCmd.Flags().IntVarP(&scanFlagCount, "count", "n", 1, "Número de intentos de escaneo por IP")
     scanCmd.Flags().IntVarP(&scanFlagThreads, "threads", "T", 50, "Número de hilos concurrentes")
+    addProviderRangesFlag(scanCmd, &scanFlagProviderRanges)
 }
 
 func scanHost(ip string, timeout, count int) bool {
@@ -70,38 +73,14 @@ func scanHost(ip string, timeout, count int) bool {
 }
 
 func scanRun(cmd *cobra.Command, args []string) {
-    var ips []string
-
-    if scanFlagCIDR != "" {
-        ip, ipnet, err := net.ParseCIDR(scanFlagCIDR)
-        if err != nil {
-            fmt.Println("Rango CIDR inválido:", err)
-            return
-        }
-        for ip := ip.Mask(ipnet.Mask); ipnet.Contains(ip); incrementIP(ip) {
-            ips = append(ips, ip.String())
-        }
-    }
-
-    if scanFlagFile != "" {
-        file, err := os.Open(scanFlagFile)
-        if err != nil {
-            fmt.Println("Error al abrir el archivo:", err)
-            return
-        }
-        defer file.Close()
-
-        scanner := bufio.NewScanner(file)
-        for scanner.Scan() {
-            ips = append(ips, scanner.Text())
-        }
-        if err := scanner.Err(); err != nil {
-            fmt.Println("Error al leer el archivo:", err)
-            return
-        }
+    ips, err := loadTargets(scanFlagCIDR, scanFlagFile, scanFlagProviderRanges)
+    if err != nil {
+        fmt.Println(err)
+        return
     }
 
-    total := len(ips)
+    total := ips.Len()
+    tracker := queuescanner.NewCtx(total)
     found := 0
     var mu sync.Mutex
     var wg sync.WaitGroup
@@ -109,7 +88,7 @@ func scanRun(cmd *cobra.Command, args []string) {
     green := color.New(color.FgGreen).SprintFunc()
     results := make([]string, 0)
 
-    for i, ip := range ips {
+    ips.Each(func(i int, ip string) {
         wg.Add(1)
         sem <- struct{}{}
         go func(i int, ip string) {
@@ -117,7 +96,12 @@ func scanRun(cmd *cobra.Command, args []string) {
             defer func() { <-sem }()
             progress := float64(i+1) / float64(total) * 100
 
-            if scanHost(ip, scanFlagTimeout, scanFlagCount) {
+            var alive bool
+            tracker.Track(func() (bool, error) {
+                alive = scanHost(ip, scanFlagTimeout, scanFlagCount)
+                return alive, nil
+            })
+            if alive {
                 mu.Lock()
                 found++
                 results = append(results, ip)
@@ -134,7 +118,7 @@ func scanRun(cmd *cobra.Command, args []string) {
                 time.Sleep(time.Duration(scanFlagDelay) * time.Millisecond)
             }
         }(i, ip)
-    }
+    })
     wg.Wait()
 
     // Asegurarse de que la línea final se muestre correctamente
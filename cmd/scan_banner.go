@@ -0,0 +1,250 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/Pablo0303/Alama/pkg/banner"
+	"github.com/Pablo0303/Alama/pkg/queuescanner"
+)
+
+var bannerCmd = &cobra.Command{
+	Use:   "banner",
+	Short: "Identifica el servicio TCP de cada puerto por su banner",
+	Long: `Conecta con cada ip:puerto y lee los primeros bytes que envía el servicio. Si
+no envía nada en --wait milisegundos se le manda un nudge (por defecto según
+el puerto: TLS, RDP, Redis o HTTP) y se lee la respuesta.
+
+El banner se compara con las firmas de SSH, SMTP, FTP, Redis, MySQL, RDP,
+HTTP y TLS. Se muestra el servicio, la versión y el banner en bruto.
+
+Los objetivos sin puerto se prueban en todos los de --ports.`,
+	Run: runScanBanner,
+}
+
+var (
+	bannerFlagCIDR           string
+	bannerFlagFile           string
+	bannerFlagOutput         string
+	bannerFlagPorts          string
+	bannerFlagNudge          string
+	bannerFlagWait           int
+	bannerFlagTimeout        int
+	bannerFlagThreads        int
+	bannerFlagProviderRanges string
+	bannerFlagResolve        bool
+)
+
+// bannerLimit es el máximo de bytes que se leen del servicio.
+const bannerLimit = 4096
+
+// bannerLineLimit es el máximo de bytes del banner que se muestran.
+const bannerLineLimit = 80
+
+// bannerIdle es cuánto se espera a que lleguen más datos tras el primer
+// fragmento del banner.
+const bannerIdle = 200 * time.Millisecond
+
+func init() {
+	scanCmd.AddCommand(bannerCmd)
+
+	bannerCmd.Flags().StringVarP(&bannerFlagCIDR, "cidr", "c", "", "Rango CIDR para escanear")
+	bannerCmd.Flags().StringVarP(&bannerFlagFile, "file", "f", "", "Archivo que contiene la lista de IPs o ip:puerto para escanear")
+	addProviderRangesFlag(bannerCmd, &bannerFlagProviderRanges)
+	bannerCmd.Flags().StringVarP(&bannerFlagOutput, "output", "o", "", "Archivo de salida para guardar los resultados")
+	bannerCmd.Flags().StringVar(&bannerFlagPorts, "ports", "21,22,25,80,443,3306,3389,6379", "Puertos a probar en los objetivos sin puerto, separados por comas")
+	bannerCmd.Flags().StringVar(&bannerFlagNudge, "nudge", banner.NudgeAuto, "Mensaje para los servicios que no hablan primero: "+strings.Join(banner.Nudges(), ", "))
+	bannerCmd.Flags().IntVar(&bannerFlagWait, "wait", 1500, "Milisegundos de espera del banner antes de enviar el nudge")
+	bannerCmd.Flags().IntVarP(&bannerFlagTimeout, "timeout", "t", 5, "Tiempo de espera de la conexión y de la respuesta en segundos")
+	addResolveFlag(bannerCmd, &bannerFlagResolve)
+	bannerCmd.Flags().IntVarP(&bannerFlagThreads, "threads", "T", 50, "Número de hilos concurrentes")
+}
+
+// bannerProbe agrupa las opciones de la sonda de banners.
+type bannerProbe struct {
+	Proxy   *outbound
+	Nudge   string
+	Wait    time.Duration // espera antes del nudge
+	Timeout time.Duration
+}
+
+// bannerResult es el servicio identificado en una dirección.
+type bannerResult struct {
+	Addr    string
+	Service string
+	Version string
+	Raw     []byte
+	Proxy   string
+}
+
+func (r *bannerResult) String() string {
+	s := r.Addr + domainSuffix(r.Addr) + " - " + r.Service
+	if r.Version != "" {
+		s += " " + r.Version
+	}
+	s += " - " + bannerLine(r.Raw)
+	if r.Proxy != "" {
+		s += " (vía " + r.Proxy + ")"
+	}
+	return s
+}
+
+// bannerLine devuelve la primera línea de un banner de texto (o el inicio de
+// uno binario), recortada y entre comillas para que los bytes binarios no
+// rompan la salida.
+func bannerLine(raw []byte) string {
+	line := raw
+	if i := bytes.IndexByte(line, '\n'); i >= 0 && isText(line[:i]) {
+		line = line[:i]
+	}
+	line = bytes.TrimRight(line, "\r")
+	if len(line) > bannerLineLimit {
+		line = line[:bannerLineLimit]
+	}
+	return strconv.QuoteToASCII(string(line))
+}
+
+// isText indica si b solo contiene ASCII imprimible, tabuladores y \r.
+func isText(b []byte) bool {
+	for _, c := range b {
+		if (c < 0x20 || c > 0x7e) && c != '\t' && c != '\r' {
+			return false
+		}
+	}
+	return true
+}
+
+// Grab conecta con addr, lee el banner (enviando el nudge si el servicio
+// calla) y lo identifica.
+func (p *bannerProbe) Grab(addr string) (*bannerResult, error) {
+	_, portStr, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	port, _ := strconv.Atoi(portStr)
+
+	out := p.Proxy.pick()
+	ctx, cancel := context.WithTimeout(context.Background(), p.Timeout)
+	defer cancel()
+	conn, err := out.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	deadline := time.Now().Add(p.Timeout)
+	wait := time.Now().Add(p.Wait)
+	if wait.After(deadline) {
+		wait = deadline
+	}
+
+	res := &bannerResult{Addr: addr, Proxy: out.Label()}
+	res.Raw, err = readBanner(conn, wait)
+	if len(res.Raw) == 0 && isTimeout(err) {
+		if nudge := banner.NudgeFor(p.Nudge, port); nudge != nil {
+			conn.SetWriteDeadline(deadline)
+			if _, err := conn.Write(nudge); err != nil {
+				return nil, err
+			}
+			res.Raw, err = readBanner(conn, deadline)
+		}
+	}
+	if len(res.Raw) == 0 {
+		if err == nil {
+			err = errors.New("el servicio no envió datos")
+		}
+		return nil, err
+	}
+
+	res.Service, res.Version = banner.Match(res.Raw)
+	return res, nil
+}
+
+// readBanner lee hasta bannerLimit bytes. Espera el primer fragmento hasta
+// deadline y los siguientes durante bannerIdle.
+func readBanner(conn net.Conn, deadline time.Time) ([]byte, error) {
+	buf := make([]byte, bannerLimit)
+	n := 0
+	conn.SetReadDeadline(deadline)
+	for n < len(buf) {
+		m, err := conn.Read(buf[n:])
+		n += m
+		if err != nil {
+			if n > 0 {
+				err = nil
+			}
+			return buf[:n], err
+		}
+		conn.SetReadDeadline(time.Now().Add(bannerIdle))
+	}
+	return buf[:n], nil
+}
+
+// isTimeout indica si err es un plazo de lectura vencido.
+func isTimeout(err error) bool {
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+func scanBanner(c *queuescanner.Ctx, p *queuescanner.QueueScannerScanParams) {
+	addr := p.Data.(string)
+
+	probe := &bannerProbe{
+		Proxy:   probeOutbound,
+		Nudge:   bannerFlagNudge,
+		Wait:    time.Duration(bannerFlagWait) * time.Millisecond,
+		Timeout: time.Duration(bannerFlagTimeout) * time.Second,
+	}
+	res, err := probe.Grab(addr)
+	if err != nil {
+		c.ScanError(addr, err, nil)
+		return
+	}
+
+	c.ScanSuccess(res, func() {
+		if res.Service == banner.Unknown {
+			c.Log(colorY1.Sprint(res))
+			return
+		}
+		c.Log(colorG1.Sprint(res))
+	})
+}
+
+func runScanBanner(cmd *cobra.Command, args []string) {
+	if !banner.ValidNudge(bannerFlagNudge) {
+		fmt.Println("Nudge inválido:", bannerFlagNudge)
+		os.Exit(1)
+	}
+
+	targets, err := loadTargets(bannerFlagCIDR, bannerFlagFile, bannerFlagProviderRanges)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	targets.WithPorts(splitList(bannerFlagPorts))
+	if bannerFlagResolve {
+		targets.Resolve(bannerFlagThreads)
+	}
+
+	queueScanner := queuescanner.NewQueueScanner(bannerFlagThreads, scanBanner)
+	queueTargets(queueScanner, targets)
+
+	queueScanner.Start(func(c *queuescanner.Ctx) {
+		results := make([]string, 0, len(c.ScanSuccessList))
+		for _, res := range c.ScanSuccessList {
+			results = append(results, res.(*bannerResult).String())
+		}
+		writeResults(bannerFlagOutput, results)
+
+		fmt.Print("\n")
+	})
+}
@@ -1,10 +1,7 @@
 package cmd
 
 import (
-    "bufio"
     "fmt"
-    "net"
-    "net/http"
     "os"
     "strings"
     "sync"
@@ -13,6 +10,8 @@ import (
     "github.com/go-ping/ping"
     "github.com/spf13/cobra"
     "github.com/fatih/color"
+
+    "github.com/Pablo0303/Alama/pkg/queuescanner"
 )
 
 // cdnSslScanCmd represents the cdnSslScan command
@@ -23,13 +22,16 @@ var cdnSslScanCmd = &cobra.Command{
 }
 
 var (
-    cdnSslFlagCIDR    string
-    cdnSslFlagFile    string
-    cdnSslFlagOutput  string
-    cdnSslFlagTimeout int
-    cdnSslFlagDelay   int
-    cdnSslFlagCount   int
-    cdnSslFlagThreads int
+    cdnSslFlagCIDR           string
+    cdnSslFlagFile           string
+    cdnSslFlagOutput         string
+    cdnSslFlagTimeout        int
+    cdnSslFlagDelay          int
+    cdnSslFlagCount          int
+    cdnSslFlagThreads        int
+    cdnSslFlagRedirect       string
+    cdnSslFlagMatch          matchFlags
+    cdnSslFlagProviderRanges string
 )
 
 func init() {
@@ -42,71 +44,57 @@ func init() {
     cdnSslScanCmd.Flags().IntVarP(&cdnSslFlagDelay, "delay", "d", 250, "Retraso entre escaneos en milisegundos")
     cdnSslScanCmd.Flags().IntVarP(&cdnSslFlagCount, "count", "n", 1, "Número de intentos de escaneo por IP")
     cdnSslScanCmd.Flags().IntVarP(&cdnSslFlagThreads, "threads", "T", 50, "Número de hilos concurrentes")
+    addProviderRangesFlag(cdnSslScanCmd, &cdnSslFlagProviderRanges)
+    addMatchFlags(cdnSslScanCmd, &cdnSslFlagMatch)
+    addRedirectFlag(cdnSslScanCmd, &cdnSslFlagRedirect)
 }
 
-func cdnSslScanHost(ip string, timeout, count int) (bool, string, string) {
+func cdnSslScanHost(ip string, timeout, count, redirects int, filter *responseFilter) (bool, *httpResult) {
     pinger, err := ping.NewPinger(ip)
     if err != nil {
-        return false, "", ""
+        return false, nil
     }
     pinger.Count = count
     pinger.Timeout = time.Duration(timeout) * time.Second
     err = pinger.Run()
     if err != nil {
-        return false, "", ""
+        return false, nil
     }
     stats := pinger.Statistics()
     if stats.PacketsRecv > 0 {
         // Realizar una solicitud HTTP para obtener la información del servidor y el código de estado
-        url := fmt.Sprintf("http://%s", ip)
-        client := &http.Client{
-            Timeout: time.Duration(timeout) * time.Second,
-        }
-        resp, err := client.Get(url)
-        if err != nil {
-            return true, "", ""
+        probe := &httpProbe{
+            Timeout:      time.Duration(timeout) * time.Second,
+            BodyLimit:    filter.BodyLimit(),
+            MaxRedirects: redirects,
+            Proxy:        probeOutbound,
         }
-        defer resp.Body.Close()
-        server := resp.Header.Get("Server")
-        status := resp.Status
-        return true, server, status
+        res, _ := probe.Fetch(ip)
+        return filter.Keep(res), res
     }
-    return false, "", ""
+    return false, nil
 }
 
 func cdnSslScanRun(cmd *cobra.Command, args []string) {
-    var ips []string
-
-    if cdnSslFlagCIDR != "" {
-        ip, ipnet, err := net.ParseCIDR(cdnSslFlagCIDR)
-        if err != nil {
-            fmt.Println("Rango CIDR inválido:", err)
-            return
-        }
-        for ip := ip.Mask(ipnet.Mask); ipnet.Contains(ip); incrementIP(ip) {
-            ips = append(ips, ip.String())
-        }
+    filter, err := cdnSslFlagMatch.compile()
+    if err != nil {
+        fmt.Println(err)
+        return
+    }
+    redirects, err := parseRedirects(cdnSslFlagRedirect)
+    if err != nil {
+        fmt.Println(err)
+        return
     }
 
-    if cdnSslFlagFile != "" {
-        file, err := os.Open(cdnSslFlagFile)
-        if err != nil {
-            fmt.Println("Error al abrir el archivo:", err)
-            return
-        }
-        defer file.Close()
-
-        scanner := bufio.NewScanner(file)
-        for scanner.Scan() {
-            ips = append(ips, scanner.Text())
-        }
-        if err := scanner.Err(); err != nil {
-            fmt.Println("Error al leer el archivo:", err)
-            return
-        }
+    ips, err := loadTargets(cdnSslFlagCIDR, cdnSslFlagFile, cdnSslFlagProviderRanges)
+    if err != nil {
+        fmt.Println(err)
+        return
     }
 
-    total := len(ips)
+    total := ips.Len()
+    tracker := queuescanner.NewCtx(total)
     found := 0
     var mu sync.Mutex
     var wg sync.WaitGroup
@@ -114,7 +102,7 @@ func cdnSslScanRun(cmd *cobra.Command, args []string) {
     green := color.New(color.FgGreen).SprintFunc()
     results := make([]string, 0)
 
-    for i, ip := range ips {
+    ips.Each(func(i int, ip string) {
         wg.Add(1)
         sem <- struct{}{}
         go func(i int, ip string) {
@@ -122,11 +110,16 @@ func cdnSslScanRun(cmd *cobra.Command, args []string) {
             defer func() { <-sem }()
             progress := float64(i+1) / float64(total) * 100
 
-            success, server, status := cdnSslScanHost(ip, cdnSslFlagTimeout, cdnSslFlagCount)
+            var success bool
+            var res *httpResult
+            tracker.Track(func() (bool, error) {
+                success, res = cdnSslScanHost(ip, cdnSslFlagTimeout, cdnSslFlagCount, redirects, filter)
+                return success, nil
+            })
             if success {
                 mu.Lock()
                 found++
-                result := fmt.Sprintf("%s - %s - %s", ip, server, status)
+                result := formatHTTPHit(ip, res)
                 results = append(results, result)
                 fmt.Printf("\n%s\n", green(result)) // Mostrar IP, servidor y estado en color verde en una línea independiente
                 mu.Unlock()
@@ -141,7 +134,7 @@ func cdnSslScanRun(cmd *cobra.Command, args []string) {
                 time.Sleep(time.Duration(cdnSslFlagDelay) * time.Millisecond)
             }
         }(i, ip)
-    }
+    })
     wg.Wait()
 
     // Asegurarse de que la línea final se muestre correctamente
@@ -1,10 +1,7 @@
 package cmd
 
 import (
-    "bufio"
     "fmt"
-    "net"
-    "net/http"
     "os"
     "strings"
     "sync"
@@ -13,6 +10,8 @@ import (
     "github.com/go-ping/ping"
     "github.com/spf13/cobra"
     "github.com/fatih/color"
+
+    "github.com/Pablo0303/Alama/pkg/queuescanner"
 )
 
 // directScanCmd represents the directScan command
@@ -23,13 +22,18 @@ var directScanCmd = &cobra.Command{
 }
 
 var (
-    directFlagCIDR    string
-    directFlagFile    string
-    directFlagOutput  string
-    directFlagTimeout int
-    directFlagDelay   int
-    directFlagCount   int
-    directFlagThreads int
+    directFlagCIDR           string
+    directFlagFile           string
+    directFlagOutput         string
+    directFlagTimeout        int
+    directFlagDelay          int
+    directFlagCount          int
+    directFlagThreads        int
+    directFlagRedirect       string
+    directFlagMatch          matchFlags
+    directFlagProviderRanges string
+    directFlagRDNS           rdnsFlags
+    directFlagResolve        bool
 )
 
 func init() {
@@ -42,79 +46,76 @@ func init() {
     directScanCmd.Flags().IntVarP(&directFlagDelay, "delay", "d", 250, "Retraso entre escaneos en milisegundos")
     directScanCmd.Flags().IntVarP(&directFlagCount, "count", "n", 1, "Número de intentos de escaneo por IP")
     directScanCmd.Flags().IntVarP(&directFlagThreads, "threads", "T", 50, "Número de hilos concurrentes")
+    addProviderRangesFlag(directScanCmd, &directFlagProviderRanges)
+    addMatchFlags(directScanCmd, &directFlagMatch)
+    addRedirectFlag(directScanCmd, &directFlagRedirect)
+    addRDNSFlags(directScanCmd, &directFlagRDNS)
+    addResolveFlag(directScanCmd, &directFlagResolve)
 }
 
-func directScanHost(ip string, timeout, count int) (bool, string, string) {
+func directScanHost(ip string, timeout, count, redirects int, filter *responseFilter) (bool, *httpResult) {
     pinger, err := ping.NewPinger(ip)
     if err != nil {
-        return false, "", ""
+        return false, nil
     }
     pinger.Count = count
     pinger.Timeout = time.Duration(timeout) * time.Second
     err = pinger.Run()
     if err != nil {
-        return false, "", ""
+        return false, nil
     }
     stats := pinger.Statistics()
     if stats.PacketsRecv > 0 {
         // Realizar una solicitud HTTP para obtener la información del servidor y el código de estado
-        url := fmt.Sprintf("http://%s", ip)
-        client := &http.Client{
-            Timeout: time.Duration(timeout) * time.Second,
-        }
-        resp, err := client.Get(url)
-        if err != nil {
-            return true, "", ""
+        probe := &httpProbe{
+            Timeout:      time.Duration(timeout) * time.Second,
+            BodyLimit:    filter.BodyLimit(),
+            MaxRedirects: redirects,
+            Proxy:        probeOutbound,
         }
-        defer resp.Body.Close()
-        server := resp.Header.Get("Server")
-        status := resp.Status
-        return true, server, status
+        res, _ := probe.forDomain(resolvedDomain(ip)).Fetch(ip)
+        return filter.Keep(res), res
     }
-    return false, "", ""
+    return false, nil
 }
 
 func directScanRun(cmd *cobra.Command, args []string) {
-    var ips []string
-
-    if directFlagCIDR != "" {
-        ip, ipnet, err := net.ParseCIDR(directFlagCIDR)
-        if err != nil {
-            fmt.Println("Rango CIDR inválido:", err)
-            return
-        }
-        for ip := ip.Mask(ipnet.Mask); ipnet.Contains(ip); incrementIP(ip) {
-            ips = append(ips, ip.String())
-        }
+    filter, err := directFlagMatch.compile()
+    if err != nil {
+        fmt.Println(err)
+        return
+    }
+    redirects, err := parseRedirects(directFlagRedirect)
+    if err != nil {
+        fmt.Println(err)
+        return
+    }
+    rdns, err := directFlagRDNS.compile()
+    if err != nil {
+        fmt.Println(err)
+        return
     }
 
-    if directFlagFile != "" {
-        file, err := os.Open(directFlagFile)
-        if err != nil {
-            fmt.Println("Error al abrir el archivo:", err)
-            return
-        }
-        defer file.Close()
-
-        scanner := bufio.NewScanner(file)
-        for scanner.Scan() {
-            ips = append(ips, scanner.Text())
-        }
-        if err := scanner.Err(); err != nil {
-            fmt.Println("Error al leer el archivo:", err)
-            return
-        }
+    ips, err := loadTargets(directFlagCIDR, directFlagFile, directFlagProviderRanges)
+    if err != nil {
+        fmt.Println(err)
+        return
+    }
+    if directFlagResolve {
+        ips.Resolve(directFlagThreads)
     }
 
-    total := len(ips)
+    total := ips.Len()
+    tracker := queuescanner.NewCtx(total)
     found := 0
     var mu sync.Mutex
     var wg sync.WaitGroup
     sem := make(chan struct{}, directFlagThreads)
     green := color.New(color.FgGreen).SprintFunc()
     results := make([]string, 0)
+    providers := make(map[string]int)
 
-    for i, ip := range ips {
+    ips.Each(func(i int, ip string) {
         wg.Add(1)
         sem <- struct{}{}
         go func(i int, ip string) {
@@ -122,11 +123,24 @@ func directScanRun(cmd *cobra.Command, args []string) {
             defer func() { <-sem }()
             progress := float64(i+1) / float64(total) * 100
 
-            success, server, status := directScanHost(ip, directFlagTimeout, directFlagCount)
+            var success bool
+            var res *httpResult
+            tracker.Track(func() (bool, error) {
+                success, res = directScanHost(ip, directFlagTimeout, directFlagCount, redirects, filter)
+                return success, nil
+            })
+            var names []string
+            if success && rdns != nil {
+                names = rdns.Lookup(ip)
+                success = rdns.Keep(names)
+            }
             if success {
                 mu.Lock()
                 found++
-                result := fmt.Sprintf("%s - %s - %s", ip, server, status)
+                result := formatHTTPHit(ip+domainSuffix(ip), res) + rdnsSuffix(names)
+                if res != nil {
+                    providers[res.Provider]++
+                }
                 results = append(results, result)
                 fmt.Printf("\n%s\n", green(result)) // Mostrar IP, servidor y estado en color verde en una línea independiente
                 mu.Unlock()
@@ -141,12 +155,16 @@ func directScanRun(cmd *cobra.Command, args []string) {
                 time.Sleep(time.Duration(directFlagDelay) * time.Millisecond)
             }
         }(i, ip)
-    }
+    })
     wg.Wait()
 
     // Asegurarse de que la línea final se muestre correctamente
     logReplace("", found, total, total, 100.00)
 
+    if len(providers) > 0 {
+        fmt.Printf("\nProveedores: %s", providerSummary(providers))
+    }
+
     if directFlagOutput != "" {
         err := os.WriteFile(directFlagOutput, []byte(strings.Join(results, "\n")), 0644)
         if err != nil {
@@ -0,0 +1,255 @@
+package cmd
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"regexp"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/Pablo0303/Alama/pkg/queuescanner"
+)
+
+var frontCmd = &cobra.Command{
+	Use:   "front",
+	Short: "Detecta domain fronting comparando SNI y cabecera Host",
+	Long: `Conecta a cada IP con SNI=--front y Host=--host, y compara la respuesta con
+la de referencia SNI=--host/Host=--host. Cada IP se clasifica como:
+
+  fronting      la respuesta coincide con la de referencia: enruta por Host
+  sni-routed    la respuesta difiere: enruta por SNI
+  rejecting     la conexión o la solicitud con nombres distintos es rechazada
+                (error, 421 o un 4xx que la referencia no da)
+  undetermined  no hay respuesta de referencia o ambos dominios sirven lo mismo
+
+Las respuestas se comparan por estado, Location y un hash del cuerpo sin los
+nombres de dominio, fechas ni identificadores de solicitud que el servidor
+repite en cada respuesta.`,
+	Run: runScanFront,
+}
+
+var (
+	frontFlagCIDR           string
+	frontFlagFile           string
+	frontFlagOutput         string
+	frontFlagFront          string
+	frontFlagHost           string
+	frontFlagPath           string
+	frontFlagPort           int
+	frontFlagTimeout        int
+	frontFlagThreads        int
+	frontFlagProviderRanges string
+)
+
+// Clases de respuesta de la detección de fronting.
+const (
+	frontClassFronting  = "fronting"
+	frontClassSNIRouted = "sni-routed"
+	frontClassRejecting = "rejecting"
+	frontClassUnknown   = "undetermined"
+)
+
+// frontBodyLimit es la cantidad de cuerpo que se lee para comparar respuestas.
+const frontBodyLimit = 4 << 10
+
+func init() {
+	scanCmd.AddCommand(frontCmd)
+
+	frontCmd.Flags().StringVarP(&frontFlagCIDR, "cidr", "c", "", "Rango CIDR para escanear")
+	frontCmd.Flags().StringVarP(&frontFlagFile, "file", "f", "", "Archivo que contiene la lista de IPs para escanear")
+	addProviderRangesFlag(frontCmd, &frontFlagProviderRanges)
+	frontCmd.Flags().StringVarP(&frontFlagOutput, "output", "o", "", "Archivo de salida para guardar los resultados")
+	frontCmd.Flags().StringVar(&frontFlagFront, "front", "", "Nombre enviado en el SNI (dominio de fachada)")
+	frontCmd.Flags().StringVar(&frontFlagHost, "host", "", "Nombre enviado en la cabecera Host (dominio real)")
+	frontCmd.Flags().StringVar(&frontFlagPath, "path", "/", "Ruta a solicitar")
+	frontCmd.Flags().IntVarP(&frontFlagPort, "port", "p", 443, "Puerto de destino")
+	frontCmd.Flags().IntVarP(&frontFlagTimeout, "timeout", "t", 5, "Tiempo de espera de cada solicitud en segundos")
+	frontCmd.Flags().IntVarP(&frontFlagThreads, "threads", "T", 50, "Número de hilos concurrentes")
+
+	frontCmd.MarkFlagRequired("front")
+	frontCmd.MarkFlagRequired("host")
+}
+
+// frontResponse resume una respuesta para poder compararla con otra.
+type frontResponse struct {
+	Status   int
+	Location string // normalizada como el cuerpo
+	BodyHash [sha1.Size]byte
+}
+
+// sameAs indica si dos respuestas parecen provenir del mismo sitio.
+func (r *frontResponse) sameAs(o *frontResponse) bool {
+	return r.Status == o.Status && r.Location == o.Location && r.BodyHash == o.BodyHash
+}
+
+// Contenido que cambia en cada respuesta del mismo sitio.
+var frontVolatileRe = regexp.MustCompile(`(?i)` +
+	`\b(?:mon|tue|wed|thu|fri|sat|sun), \d{1,2} [a-z]{3} \d{4} \d{2}:\d{2}:\d{2}(?: gmt)?` + // fechas HTTP
+	`|\b\d{4}-\d{2}-\d{2}[t ]\d{2}:\d{2}:\d{2}(?:\.\d+)?(?:z|[+-]\d{2}:?\d{2})?` + // fechas ISO
+	`|\b1\d{9,12}\b` + // marcas de tiempo Unix
+	`|\b[0-9a-f]{16,}\b`) // identificadores de solicitud (Ray ID, x-amz-cf-id...)
+
+// normalizeFront quita de una respuesta los nombres enviados, que el
+// servidor puede repetir, y el contenido volátil, para poder comparar
+// respuestas por hash.
+func normalizeFront(body []byte, names ...string) []byte {
+	for _, name := range names {
+		if name != "" {
+			body = regexp.MustCompile(`(?i)`+regexp.QuoteMeta(name)).ReplaceAll(body, []byte("{name}"))
+		}
+	}
+	body = frontVolatileRe.ReplaceAll(body, []byte("{var}"))
+	return bytes.Join(bytes.Fields(body), []byte(" "))
+}
+
+// frontResult es la clasificación de una IP.
+type frontResult struct {
+	IP         string
+	Front      string
+	Host       string
+	Class      string
+	Status     int
+	BaseStatus int
+	Proxy      string // proxy de --proxy-file por el que salieron las solicitudes
+}
+
+func (r *frontResult) String() string {
+	s := fmt.Sprintf("%s - %s - sni=%s host=%s - %d/%d", r.IP, r.Class, r.Front, r.Host, r.Status, r.BaseStatus)
+	if r.Proxy != "" {
+		s += " (vía " + r.Proxy + ")"
+	}
+	return s
+}
+
+// frontRequest hace una solicitud HTTPS a ip con el SNI y Host indicados a
+// través de out.
+func frontRequest(out *outbound, ip, sni, host string) (*frontResponse, error) {
+	probe := &httpProbe{
+		Scheme:  "https",
+		Port:    frontFlagPort,
+		SNI:     sni,
+		Timeout: time.Duration(frontFlagTimeout) * time.Second,
+		Proxy:   out,
+	}
+	// Sin MaxRedirects las redirecciones forman parte de la respuesta a
+	// comparar.
+	client, err := probe.Client()
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest(http.MethodGet, probe.URL(ip)+frontFlagPath, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Host = host
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, frontBodyLimit))
+	location := normalizeFront([]byte(resp.Header.Get("Location")), sni, host)
+	return &frontResponse{
+		Status:   resp.StatusCode,
+		Location: string(location),
+		BodyHash: sha1.Sum(normalizeFront(body, sni, host)),
+	}, nil
+}
+
+// classifyFront compara la respuesta con nombres cruzados con las respuestas
+// de referencia del dominio real (base) y del dominio de fachada (front).
+func classifyFront(fronted, base, front *frontResponse) string {
+	if fronted == nil || fronted.Status == http.StatusMisdirectedRequest {
+		return frontClassRejecting
+	}
+	if base == nil {
+		// Sin referencia no se sabe qué sirve el dominio real.
+		return frontClassUnknown
+	}
+	if fronted.sameAs(base) {
+		if front != nil && fronted.sameAs(front) {
+			// Ambos dominios sirven lo mismo en esta IP: no hay
+			// forma de distinguir el enrutamiento.
+			return frontClassUnknown
+		}
+		return frontClassFronting
+	}
+	// Las CDN responden a los nombres cruzados con páginas 400/403 de
+	// "host no coincide".
+	if fronted.Status >= 400 && fronted.Status < 500 && fronted.Status != base.Status {
+		return frontClassRejecting
+	}
+	return frontClassSNIRouted
+}
+
+func scanFront(c *queuescanner.Ctx, p *queuescanner.QueueScannerScanParams) {
+	ip := p.Data.(string)
+
+	// Las respuestas a comparar deben salir por el mismo proxy.
+	out := probeOutbound.pick()
+
+	fronted, _ := frontRequest(out, ip, frontFlagFront, frontFlagHost)
+	base, _ := frontRequest(out, ip, frontFlagHost, frontFlagHost)
+	var front *frontResponse
+	if fronted != nil && base != nil && fronted.sameAs(base) {
+		front, _ = frontRequest(out, ip, frontFlagFront, frontFlagFront)
+	}
+
+	res := &frontResult{
+		IP:    ip,
+		Front: frontFlagFront,
+		Host:  frontFlagHost,
+		Class: classifyFront(fronted, base, front),
+		Proxy: out.Label(),
+	}
+	if fronted != nil {
+		res.Status = fronted.Status
+	}
+	if base != nil {
+		res.BaseStatus = base.Status
+	}
+
+	switch res.Class {
+	case frontClassFronting:
+		c.ScanSuccess(res, func() {
+			c.Log(colorG1.Sprint(res))
+		})
+	case frontClassSNIRouted:
+		c.ScanFailed(res, func() {
+			c.Log(colorY1.Sprint(res))
+		})
+	default:
+		c.ScanFailed(res, nil)
+	}
+}
+
+func runScanFront(cmd *cobra.Command, args []string) {
+	ips, err := loadTargets(frontFlagCIDR, frontFlagFile, frontFlagProviderRanges)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	queueScanner := queuescanner.NewQueueScanner(frontFlagThreads, scanFront)
+	queueTargets(queueScanner, ips)
+
+	queueScanner.Start(func(c *queuescanner.Ctx) {
+		var results []string
+		for _, list := range [][]interface{}{c.ScanSuccessList, c.ScanFailedList} {
+			for _, res := range list {
+				results = append(results, res.(*frontResult).String())
+			}
+		}
+		writeResults(frontFlagOutput, results)
+
+		fmt.Print("\n")
+	})
+}
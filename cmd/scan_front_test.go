@@ -0,0 +1,64 @@
+package cmd
+
+import (
+	"crypto/sha1"
+	"net/http"
+	"testing"
+)
+
+func frontResp(status int, body string) *frontResponse {
+	return &frontResponse{Status: status, BodyHash: sha1.Sum(normalizeFront([]byte(body), "front.example", "real.example"))}
+}
+
+func TestNormalizeFront(t *testing.T) {
+	same := [][2]string{
+		{"<p>Hola real.example</p>", "<p>Hola FRONT.example</p>"},
+		{"Date: Mon, 02 Jan 2006 15:04:05 GMT", "Date: Tue, 03 Jan 2006 10:00:00 GMT"},
+		{"t=2024-01-02T03:04:05Z", "t=2025-06-07 08:09:10.123+02:00"},
+		{"Ray ID: 8a1b2c3d4e5f6a7b", "Ray ID: 0011223344556677"},
+		{"ts 1700000000", "ts 1712345678901"},
+		{"a  b\n\tc", "a b c"},
+	}
+	for _, tt := range same {
+		a := normalizeFront([]byte(tt[0]), "front.example", "real.example")
+		b := normalizeFront([]byte(tt[1]), "front.example", "real.example")
+		if string(a) != string(b) {
+			t.Errorf("%q y %q difieren: %q != %q", tt[0], tt[1], a, b)
+		}
+	}
+
+	// Dos páginas distintas del mismo tamaño no se confunden.
+	a := normalizeFront([]byte("<h1>Bienvenido a la tienda</h1>"))
+	b := normalizeFront([]byte("<h1>Bienvenido al foro web</h1>"))
+	if string(a) == string(b) {
+		t.Errorf("páginas distintas normalizadas igual: %q", a)
+	}
+}
+
+func TestClassifyFront(t *testing.T) {
+	page := frontResp(http.StatusOK, "<h1>real</h1>")
+	other := frontResp(http.StatusOK, "<h1>fachada</h1>")
+	similar := frontResp(http.StatusOK, "<h1>rael</h1>") // mismo tamaño, otro contenido
+
+	tests := []struct {
+		name                 string
+		fronted, base, front *frontResponse
+		want                 string
+	}{
+		{"sin respuesta", nil, page, nil, frontClassRejecting},
+		{"421", frontResp(http.StatusMisdirectedRequest, ""), page, nil, frontClassRejecting},
+		{"403 de host no coincidente", frontResp(http.StatusForbidden, "mismatch"), page, nil, frontClassRejecting},
+		{"400 de host no coincidente", frontResp(http.StatusBadRequest, "bad host"), page, nil, frontClassRejecting},
+		{"sin referencia", page, nil, nil, frontClassUnknown},
+		{"igual a la referencia", page, page, other, frontClassFronting},
+		{"igual a ambos dominios", page, page, page, frontClassUnknown},
+		{"distinto de la referencia", other, page, nil, frontClassSNIRouted},
+		{"tamaño parecido", similar, page, nil, frontClassSNIRouted},
+		{"404 en ambos", frontResp(http.StatusNotFound, "a"), frontResp(http.StatusNotFound, "b"), nil, frontClassSNIRouted},
+	}
+	for _, tt := range tests {
+		if got := classifyFront(tt.fronted, tt.base, tt.front); got != tt.want {
+			t.Errorf("%s: clase %s, se esperaba %s", tt.name, got, tt.want)
+		}
+	}
+}
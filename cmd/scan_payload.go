@@ -0,0 +1,296 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/Pablo0303/Alama/pkg/queuescanner"
+)
+
+var payloadCmd = &cobra.Command{
+	Use:   "payload",
+	Short: "Envía un payload HTTP crudo a cada objetivo",
+	Long: `Envía un payload HTTP crudo sobre TCP o TLS y muestra las respuestas recibidas.
+
+Marcadores disponibles en --payload:
+  [target]  objetivo escaneado (IP o host)
+  [host]    valor de --host (por defecto el objetivo)
+  [port]    puerto de destino
+  [crlf]    \r\n
+  [cr]      \r
+  [lf]      \n
+  [split]   divide el payload: cada parte se envía por separado
+  [random]  cadena aleatoria de 8 caracteres
+
+Ejemplo:
+  Alama scan payload -f ips.lst --host ws.example.com \
+    --payload 'GET / HTTP/1.1[crlf]Host: [host][crlf]Upgrade: websocket[crlf][crlf]'`,
+	Run: runScanPayload,
+}
+
+var (
+	payloadFlagCIDR           string
+	payloadFlagFile           string
+	payloadFlagOutput         string
+	payloadFlagPayload        string
+	payloadFlagHost           string
+	payloadFlagPort           int
+	payloadFlagTLS            bool
+	payloadFlagSNI            string
+	payloadFlagSplitDelay     int
+	payloadFlagTimeout        int
+	payloadFlagThreads        int
+	payloadFlagMatch          matchFlags
+	payloadFlagProviderRanges string
+)
+
+// payloadFilter es el filtro de respuestas compilado a partir de las banderas.
+var payloadFilter *responseFilter
+
+// payloadSplit separa las partes de un payload ya procesado.
+const payloadSplit = "\x00split\x00"
+
+// payloadBodyLimit limita lo que se descarta del cuerpo de cada respuesta
+// antes de leer la siguiente.
+const payloadBodyLimit = 64 << 10
+
+func init() {
+	scanCmd.AddCommand(payloadCmd)
+
+	payloadCmd.Flags().StringVarP(&payloadFlagCIDR, "cidr", "c", "", "Rango CIDR para escanear")
+	payloadCmd.Flags().StringVarP(&payloadFlagFile, "file", "f", "", "Archivo que contiene la lista de IPs/hosts para escanear")
+	addProviderRangesFlag(payloadCmd, &payloadFlagProviderRanges)
+	payloadCmd.Flags().StringVarP(&payloadFlagOutput, "output", "o", "", "Archivo de salida para guardar los resultados")
+	payloadCmd.Flags().StringVar(&payloadFlagPayload, "payload", "GET / HTTP/1.1[crlf]Host: [host][crlf][crlf]", "Plantilla del payload")
+	payloadCmd.Flags().StringVar(&payloadFlagHost, "host", "", "Valor del marcador [host]")
+	payloadCmd.Flags().IntVarP(&payloadFlagPort, "port", "p", 80, "Puerto de destino")
+	payloadCmd.Flags().BoolVar(&payloadFlagTLS, "tls", false, "Envía el payload sobre TLS")
+	payloadCmd.Flags().StringVar(&payloadFlagSNI, "sni", "", "ServerName TLS (por defecto el valor de [host])")
+	payloadCmd.Flags().IntVar(&payloadFlagSplitDelay, "split-delay", 200, "Retraso entre partes del payload en milisegundos")
+	payloadCmd.Flags().IntVarP(&payloadFlagTimeout, "timeout", "t", 5, "Tiempo de espera de la conexión en segundos")
+	payloadCmd.Flags().IntVarP(&payloadFlagThreads, "threads", "T", 50, "Número de hilos concurrentes")
+	addMatchFlags(payloadCmd, &payloadFlagMatch)
+}
+
+// renderPayload sustituye los marcadores de la plantilla y devuelve las partes
+// a enviar.
+func renderPayload(tmpl, target, host string, port int) []string {
+	if host == "" {
+		host = target
+	}
+
+	r := strings.NewReplacer(
+		"[target]", target,
+		"[host]", host,
+		"[port]", strconv.Itoa(port),
+		"[crlf]", "\r\n",
+		"[cr]", "\r",
+		"[lf]", "\n",
+		"[split]", payloadSplit,
+	)
+	s := r.Replace(tmpl)
+
+	for strings.Contains(s, "[random]") {
+		s = strings.Replace(s, "[random]", randomToken(8), 1)
+	}
+
+	return strings.Split(s, payloadSplit)
+}
+
+// randomToken devuelve una cadena alfanumérica aleatoria de n caracteres.
+func randomToken(n int) string {
+	const letters = "abcdefghijklmnopqrstuvwxyz0123456789"
+
+	b := make([]byte, n)
+	for i := range b {
+		j, err := rand.Int(rand.Reader, big.NewInt(int64(len(letters))))
+		if err != nil {
+			return strings.Repeat("x", n)
+		}
+		b[i] = letters[j.Int64()]
+	}
+	return string(b)
+}
+
+// payloadResponse es una respuesta HTTP leída tras enviar el payload.
+type payloadResponse struct {
+	StatusLine string
+	*httpResult
+}
+
+// payloadResult agrupa todas las respuestas recibidas de un objetivo.
+type payloadResult struct {
+	Target    string
+	Responses []*payloadResponse
+	Proxy     string // proxy de --proxy-file por el que salió el payload
+}
+
+func (r *payloadResult) String() string {
+	parts := make([]string, 0, len(r.Responses))
+	for _, resp := range r.Responses {
+		s := resp.StatusLine
+		if server := resp.Server(); server != "" {
+			s += " (" + server + ")"
+		}
+		parts = append(parts, s)
+	}
+	s := fmt.Sprintf("%s - %s", r.Target, strings.Join(parts, " | "))
+	if r.Proxy != "" {
+		s += " (vía " + r.Proxy + ")"
+	}
+	return s
+}
+
+// keep indica si alguna de las respuestas cumple el filtro.
+func (r *payloadResult) keep(filter *responseFilter) bool {
+	for _, resp := range r.Responses {
+		if filter.Keep(resp.httpResult) {
+			return true
+		}
+	}
+	return false
+}
+
+// readPayloadResponses lee respuestas HTTP consecutivas hasta que la conexión
+// se cierra, vence el plazo o se cambia de protocolo. De cada respuesta se
+// conservan hasta bodyLimit bytes del cuerpo.
+func readPayloadResponses(r *bufio.Reader, target string, bodyLimit int64) []*payloadResponse {
+	var responses []*payloadResponse
+
+	for {
+		resp, err := http.ReadResponse(r, nil)
+		if err != nil {
+			break
+		}
+
+		res := &httpResult{
+			Target:        target,
+			StatusCode:    resp.StatusCode,
+			Status:        resp.Status,
+			Header:        resp.Header,
+			ContentLength: resp.ContentLength,
+		}
+		responses = append(responses, &payloadResponse{
+			StatusLine: fmt.Sprintf("%s %s", resp.Proto, resp.Status),
+			httpResult: res,
+		})
+
+		if resp.StatusCode == http.StatusSwitchingProtocols {
+			break
+		}
+
+		if bodyLimit > 0 {
+			res.Body, _ = io.ReadAll(io.LimitReader(resp.Body, bodyLimit))
+		}
+		io.Copy(io.Discard, io.LimitReader(resp.Body, payloadBodyLimit))
+		resp.Body.Close()
+	}
+
+	return responses
+}
+
+func scanPayload(c *queuescanner.Ctx, p *queuescanner.QueueScannerScanParams) {
+	target := p.Data.(string)
+	filter := payloadFilter
+	timeout := time.Duration(payloadFlagTimeout) * time.Second
+
+	addr := net.JoinHostPort(target, strconv.Itoa(payloadFlagPort))
+	out := probeOutbound.pick()
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	conn, err := out.DialContext(ctx, "tcp", addr)
+	cancel()
+	if err != nil {
+		c.ScanError(target, err, nil)
+		return
+	}
+	defer conn.Close()
+
+	host := payloadFlagHost
+	if host == "" {
+		host = target
+	}
+
+	if payloadFlagTLS {
+		sni := payloadFlagSNI
+		if sni == "" {
+			sni = host
+		}
+		tlsConn := tls.Client(conn, &tls.Config{
+			ServerName:         sni,
+			InsecureSkipVerify: true,
+		})
+		tlsConn.SetDeadline(time.Now().Add(timeout))
+		if err := tlsConn.Handshake(); err != nil {
+			c.ScanError(target, err, nil)
+			return
+		}
+		conn = tlsConn
+	}
+
+	parts := renderPayload(payloadFlagPayload, target, host, payloadFlagPort)
+	for i, part := range parts {
+		if i > 0 && payloadFlagSplitDelay > 0 {
+			time.Sleep(time.Duration(payloadFlagSplitDelay) * time.Millisecond)
+		}
+		conn.SetWriteDeadline(time.Now().Add(timeout))
+		if _, err := conn.Write([]byte(part)); err != nil {
+			c.ScanError(target, err, nil)
+			return
+		}
+	}
+
+	conn.SetReadDeadline(time.Now().Add(timeout))
+	res := &payloadResult{
+		Target:    target,
+		Responses: readPayloadResponses(bufio.NewReader(conn), target, filter.BodyLimit()),
+		Proxy:     out.Label(),
+	}
+	if len(res.Responses) == 0 || !res.keep(filter) {
+		c.ScanFailed(target, nil)
+		return
+	}
+
+	c.ScanSuccess(res, func() {
+		c.Log(colorG1.Sprint(res))
+	})
+}
+
+func runScanPayload(cmd *cobra.Command, args []string) {
+	targets, err := loadTargets(payloadFlagCIDR, payloadFlagFile, payloadFlagProviderRanges)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	payloadFilter, err = payloadFlagMatch.compile()
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	queueScanner := queuescanner.NewQueueScanner(payloadFlagThreads, scanPayload)
+	queueTargets(queueScanner, targets)
+
+	queueScanner.Start(func(c *queuescanner.Ctx) {
+		results := make([]string, 0, len(c.ScanSuccessList))
+		for _, res := range c.ScanSuccessList {
+			results = append(results, res.(*payloadResult).String())
+		}
+		writeResults(payloadFlagOutput, results)
+
+		fmt.Print("\n")
+	})
+}
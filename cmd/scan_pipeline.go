@@ -0,0 +1,142 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/Pablo0303/Alama/pkg/queuescanner"
+)
+
+var pipelineCmd = &cobra.Command{
+	Use:   "pipeline",
+	Short: "Pasa cada objetivo por una cadena de sondas configurable",
+	Long: `Ejecuta en orden las etapas de --stages (o de --stages-file) sobre cada
+objetivo. Un objetivo que no supera una etapa se descarta; con ? al final la
+etapa es opcional y solo añade información.
+
+Etapas:
+  ping[:N]        responde a N pings ICMP
+  tcp:PUERTO      acepta conexiones en el puerto
+  tls[:SNI]       completa un handshake TLS (puerto 443 si no hay otro)
+  http[:/ruta]    responde por HTTP, o HTTPS si pasó la etapa tls, y cumple
+                  --match/--filter
+  https[:/ruta]   igual, siempre por HTTPS
+  banner[:nudge]  identifica el servicio del puerto
+
+Cada etapa usa lo que dejaron las anteriores: tcp fija el puerto de tls,
+http y banner, y tls el esquema y el SNI de http. El archivo de
+--stages-file es YAML con una lista stages de objetos con name y las
+opciones de la etapa (port, count, sni, host, path, match, filter, nudge y
+optional).
+
+Al terminar se muestra el embudo: cuántos objetivos superó cada etapa.
+
+Ejemplos:
+  Alama scan pipeline -c 104.16.0.0/24 --stages tcp:443,tls,http
+  Alama scan pipeline -f hosts.txt --stages ping?,tcp:80,http:/health -s 200`,
+	Run: runScanPipeline,
+}
+
+var (
+	pipelineFlagCIDR           string
+	pipelineFlagFile           string
+	pipelineFlagOutput         string
+	pipelineFlagStages         string
+	pipelineFlagStagesFile     string
+	pipelineFlagTimeout        int
+	pipelineFlagThreads        int
+	pipelineFlagRedirect       string
+	pipelineFlagMatch          matchFlags
+	pipelineFlagProviderRanges string
+	pipelineFlagResolve        bool
+)
+
+func init() {
+	scanCmd.AddCommand(pipelineCmd)
+
+	pipelineCmd.Flags().StringVarP(&pipelineFlagCIDR, "cidr", "c", "", "Rango CIDR para escanear")
+	pipelineCmd.Flags().StringVarP(&pipelineFlagFile, "file", "f", "", "Archivo que contiene la lista de IPs, hosts o ip:puerto para escanear")
+	addProviderRangesFlag(pipelineCmd, &pipelineFlagProviderRanges)
+	pipelineCmd.Flags().StringVarP(&pipelineFlagOutput, "output", "o", "", "Archivo de salida para guardar los resultados")
+	pipelineCmd.Flags().StringVar(&pipelineFlagStages, "stages", "tcp:443,tls,http", "Etapas separadas por comas (ej. ping?,tcp:443,tls,http)")
+	pipelineCmd.Flags().StringVar(&pipelineFlagStagesFile, "stages-file", "", "Archivo YAML con las etapas; sustituye a --stages")
+	pipelineCmd.Flags().IntVarP(&pipelineFlagTimeout, "timeout", "t", 3, "Tiempo de espera de cada etapa en segundos")
+	pipelineCmd.Flags().IntVarP(&pipelineFlagThreads, "threads", "T", 50, "Número de hilos concurrentes")
+	addMatchFlags(pipelineCmd, &pipelineFlagMatch)
+	addRedirectFlag(pipelineCmd, &pipelineFlagRedirect)
+	addResolveFlag(pipelineCmd, &pipelineFlagResolve)
+
+	pipelineCmd.MarkFlagFilename("stages-file", "yaml", "yml")
+}
+
+func scanPipeline(pl *pipeline) queuescanner.QueueScannerScanFunc {
+	return func(c *queuescanner.Ctx, p *queuescanner.QueueScannerScanParams) {
+		res := pl.Run(p.Data.(string))
+		if !res.Passed {
+			c.ScanFailed(res, nil)
+			return
+		}
+		c.ScanSuccess(res, func() {
+			c.Log(colorG1.Sprint(res))
+		})
+	}
+}
+
+func runScanPipeline(cmd *cobra.Command, args []string) {
+	var configs []stageConfig
+	var err error
+	if pipelineFlagStagesFile != "" {
+		configs, err = loadStages(pipelineFlagStagesFile)
+	} else {
+		configs, err = parseStages(pipelineFlagStages)
+	}
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	filter, err := pipelineFlagMatch.compile()
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	redirects, err := parseRedirects(pipelineFlagRedirect)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	pl, err := newPipeline(configs, pipelineOptions{
+		Timeout:   time.Duration(pipelineFlagTimeout) * time.Second,
+		Redirects: redirects,
+		Filter:    filter,
+	})
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	targets, err := loadTargets(pipelineFlagCIDR, pipelineFlagFile, pipelineFlagProviderRanges)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	if pipelineFlagResolve {
+		targets.Resolve(pipelineFlagThreads)
+	}
+
+	queueScanner := queuescanner.NewQueueScanner(pipelineFlagThreads, scanPipeline(pl))
+	queueTargets(queueScanner, targets)
+
+	queueScanner.Start(func(c *queuescanner.Ctx) {
+		results := make([]string, 0, len(c.ScanSuccessList))
+		for _, res := range c.ScanSuccessList {
+			results = append(results, res.(*pipelineResult).String())
+		}
+		writeResults(pipelineFlagOutput, results)
+
+		fmt.Printf("\nEmbudo:\n%s", pl.Funnel())
+	})
+}
@@ -1,11 +1,7 @@
 package cmd
 
 import (
-    "bufio"
     "fmt"
-    "net"
-    "net/http"
-    "net/url"
     "os"
     "strings"
     "sync"
@@ -14,24 +10,39 @@ import (
     "github.com/go-ping/ping"
     "github.com/spf13/cobra"
     "github.com/fatih/color"
+
+    "github.com/Pablo0303/Alama/pkg/queuescanner"
 )
 
 // proxyScanCmd represents the proxyScan command
 var proxyScanCmd = &cobra.Command{
     Use:   "proxy",
     Short: "Scan a range of IPs or a list of IPs/hosts for proxies",
+    Long: `Sin --open, hace ping a cada objetivo y lo solicita a través de --proxy.
+
+Con --open, prueba cada ip:puerto como proxy HTTP, túnel CONNECT, SOCKS4 y
+SOCKS5 pidiendo --check-url, e indica el protocolo, el nivel de anonimato
+(transparent, anonymous o elite) y la latencia. La URL de comprobación debe
+devolver las cabeceras recibidas; para medir el anonimato se compara con la
+respuesta obtenida sin proxy.`,
     Run:   proxyScanRun,
 }
 
 var (
-    proxyFlagCIDR    string
-    proxyFlagFile    string
-    proxyFlagOutput  string
-    proxyFlagTimeout int
-    proxyFlagDelay   int
-    proxyFlagCount   int
-    proxyFlagThreads int
-    proxyFlagProxy   string
+    proxyFlagCIDR           string
+    proxyFlagFile           string
+    proxyFlagOutput         string
+    proxyFlagTimeout        int
+    proxyFlagDelay          int
+    proxyFlagCount          int
+    proxyFlagThreads        int
+    proxyFlagRedirect       string
+    proxyFlagMatch          matchFlags
+    proxyFlagProviderRanges string
+    proxyFlagOpen           bool
+    proxyFlagCheckURL       string
+    proxyFlagPorts          string
+    proxyFlagRealIP         string
 )
 
 func init() {
@@ -44,89 +55,79 @@ func init() {
     proxyScanCmd.Flags().IntVarP(&proxyFlagDelay, "delay", "d", 250, "Retraso entre escaneos en milisegundos")
     proxyScanCmd.Flags().IntVarP(&proxyFlagCount, "count", "n", 1, "Número de intentos de escaneo por IP")
     proxyScanCmd.Flags().IntVarP(&proxyFlagThreads, "threads", "T", 50, "Número de hilos concurrentes")
-    proxyScanCmd.Flags().StringVarP(&proxyFlagProxy, "proxy", "x", "", "Proxy y puerto a usar (ej., 192.168.1.1:8080)")
+    addProviderRangesFlag(proxyScanCmd, &proxyFlagProviderRanges)
+    addMatchFlags(proxyScanCmd, &proxyFlagMatch)
+    addRedirectFlag(proxyScanCmd, &proxyFlagRedirect)
+    proxyScanCmd.Flags().BoolVar(&proxyFlagOpen, "open", false, "Comprueba si los objetivos son proxies abiertos")
+    proxyScanCmd.Flags().StringVar(&proxyFlagCheckURL, "check-url", "http://httpbin.org/get", "URL que se pide a través de cada proxy con --open")
+    proxyScanCmd.Flags().StringVar(&proxyFlagPorts, "ports", "3128,8080,1080", "Puertos a probar con --open si el objetivo no indica uno")
+    proxyScanCmd.Flags().StringVar(&proxyFlagRealIP, "real-ip", "", "IP real para detectar proxies transparentes (por defecto la que muestra --check-url)")
 }
 
-func proxyScanHost(ip string, timeout, count int, proxy string) (bool, string, string) {
+func proxyScanHost(ip string, timeout, count, redirects int, proxy *outbound, filter *responseFilter) (bool, *httpResult) {
     pinger, err := ping.NewPinger(ip)
     if err != nil {
-        return false, "", ""
+        return false, nil
     }
     pinger.Count = count
     pinger.Timeout = time.Duration(timeout) * time.Second
     err = pinger.Run()
     if err != nil {
-        return false, "", ""
+        return false, nil
     }
     stats := pinger.Statistics()
     if stats.PacketsRecv > 0 {
         // Realizar una solicitud HTTP para obtener la información del servidor y el código de estado
-        urlStr := fmt.Sprintf("http://%s", ip)
-        client := &http.Client{
-            Timeout: time.Duration(timeout) * time.Second,
-        }
-        if proxy != "" {
-            proxyURL, err := url.Parse(fmt.Sprintf("http://%s", proxy))
-            if err != nil {
-                return true, "", ""
-            }
-            client.Transport = &http.Transport{
-                Proxy: http.ProxyURL(proxyURL),
-            }
-        }
-        resp, err := client.Get(urlStr)
-        if err != nil {
-            return true, "", ""
+        probe := &httpProbe{
+            Timeout:      time.Duration(timeout) * time.Second,
+            BodyLimit:    filter.BodyLimit(),
+            MaxRedirects: redirects,
+            Proxy:        proxy,
         }
-        defer resp.Body.Close()
-        server := resp.Header.Get("Server")
-        status := resp.Status
-        return true, server, status
+        res, _ := probe.Fetch(ip)
+        return filter.Keep(res), res
     }
-    return false, "", ""
+    return false, nil
 }
 
 func proxyScanRun(cmd *cobra.Command, args []string) {
-    var ips []string
-
-    if proxyFlagCIDR != "" {
-        ip, ipnet, err := net.ParseCIDR(proxyFlagCIDR)
-        if err != nil {
-            fmt.Println("Rango CIDR inválido:", err)
-            return
-        }
-        for ip := ip.Mask(ipnet.Mask); ipnet.Contains(ip); incrementIP(ip) {
-            ips = append(ips, ip.String())
-        }
+    if proxyFlagOpen {
+        openProxyRun()
+        return
+    }
+    if probeOutbound == nil {
+        fmt.Println("Indica el proxy con --proxy o --system-proxy")
+        return
     }
 
-    if proxyFlagFile != "" {
-        file, err := os.Open(proxyFlagFile)
-        if err != nil {
-            fmt.Println("Error al abrir el archivo:", err)
-            return
-        }
-        defer file.Close()
+    filter, err := proxyFlagMatch.compile()
+    if err != nil {
+        fmt.Println(err)
+        return
+    }
+    redirects, err := parseRedirects(proxyFlagRedirect)
+    if err != nil {
+        fmt.Println(err)
+        return
+    }
 
-        scanner := bufio.NewScanner(file)
-        for scanner.Scan() {
-            ips = append(ips, scanner.Text())
-        }
-        if err := scanner.Err(); err != nil {
-            fmt.Println("Error al leer el archivo:", err)
-            return
-        }
+    ips, err := loadTargets(proxyFlagCIDR, proxyFlagFile, proxyFlagProviderRanges)
+    if err != nil {
+        fmt.Println(err)
+        return
     }
 
-    total := len(ips)
+    total := ips.Len()
+    tracker := queuescanner.NewCtx(total)
     found := 0
     var mu sync.Mutex
     var wg sync.WaitGroup
     sem := make(chan struct{}, proxyFlagThreads)
     green := color.New(color.FgGreen).SprintFunc()
     results := make([]string, 0)
+    providers := make(map[string]int)
 
-    for i, ip := range ips {
+    ips.Each(func(i int, ip string) {
         wg.Add(1)
         sem <- struct{}{}
         go func(i int, ip string) {
@@ -134,11 +135,19 @@ func proxyScanRun(cmd *cobra.Command, args []string) {
             defer func() { <-sem }()
             progress := float64(i+1) / float64(total) * 100
 
-            success, server, status := proxyScanHost(ip, proxyFlagTimeout, proxyFlagCount, proxyFlagProxy)
+            var success bool
+            var res *httpResult
+            tracker.Track(func() (bool, error) {
+                success, res = proxyScanHost(ip, proxyFlagTimeout, proxyFlagCount, redirects, probeOutbound, filter)
+                return success, nil
+            })
             if success {
                 mu.Lock()
                 found++
-                result := fmt.Sprintf("%s - %s - %s", ip, server, status)
+                result := formatHTTPHit(ip, res)
+                if res != nil {
+                    providers[res.Provider]++
+                }
                 results = append(results, result)
                 fmt.Printf("\n%s\n", green(result)) // Mostrar IP, servidor y estado en color verde en una línea independiente
                 mu.Unlock()
@@ -153,12 +162,16 @@ func proxyScanRun(cmd *cobra.Command, args []string) {
                 time.Sleep(time.Duration(proxyFlagDelay) * time.Millisecond)
             }
         }(i, ip)
-    }
+    })
     wg.Wait()
 
     // Asegurarse de que la línea final se muestre correctamente
     logReplace("", found, total, total, 100.00)
 
+    if len(providers) > 0 {
+        fmt.Printf("\nProveedores: %s", providerSummary(providers))
+    }
+
     if proxyFlagOutput != "" {
         err := os.WriteFile(proxyFlagOutput, []byte(strings.Join(results, "\n")), 0644)
         if err != nil {
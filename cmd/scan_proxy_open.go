@@ -0,0 +1,273 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/Pablo0303/Alama/pkg/proxydial"
+	"github.com/Pablo0303/Alama/pkg/queuescanner"
+)
+
+// Protocolos de proxy que se prueban con --open, en este orden.
+const (
+	proxyProtoHTTP    = "http"
+	proxyProtoConnect = "connect"
+	proxyProtoSOCKS4  = "socks4"
+	proxyProtoSOCKS5  = "socks5"
+)
+
+var openProxyProtocols = []string{proxyProtoHTTP, proxyProtoConnect, proxyProtoSOCKS4, proxyProtoSOCKS5}
+
+// Niveles de anonimato de un proxy abierto.
+const (
+	anonTransparent = "transparent" // la IP real llega al destino
+	anonAnonymous   = "anonymous"   // oculta la IP pero se identifica como proxy
+	anonElite       = "elite"       // no deja rastro en las cabeceras
+)
+
+// openProxyCheckBodyLimit limita lo que se lee de la URL de comprobación.
+const openProxyCheckBodyLimit = 64 << 10
+
+// proxyHeaderPattern encuentra en el eco de la URL de comprobación las
+// cabeceras que añaden los proxies.
+var proxyHeaderPattern = regexp.MustCompile(`(?i)"?\b(via|x-forwarded-for|x-forwarded-host|forwarded|x-real-ip|client-ip|x-client-ip|x-proxy-id|proxy-connection)"?\s*[:=]`)
+
+// ipPattern encuentra direcciones IPv4 en el eco de la URL de comprobación.
+var ipPattern = regexp.MustCompile(`\b(?:\d{1,3}\.){3}\d{1,3}\b`)
+
+// openProxyCheck es la respuesta directa de la URL de comprobación con la
+// que se comparan las obtenidas a través de cada proxy.
+type openProxyCheck struct {
+	URL        string
+	StatusCode int
+	Server     string
+	RealIPs    []string
+	Headers    map[string]bool // cabeceras de proxy ya presentes sin proxy
+}
+
+// openProxyHit es un protocolo que funcionó en un objetivo.
+type openProxyHit struct {
+	Protocol  string
+	Anonymity string
+	Latency   time.Duration
+	Leaked    []string
+}
+
+func (h *openProxyHit) String() string {
+	level := h.Anonymity
+	if len(h.Leaked) > 0 {
+		level += " " + strings.Join(h.Leaked, ",")
+	}
+	return fmt.Sprintf("%s(%s, %dms)", h.Protocol, level, h.Latency.Milliseconds())
+}
+
+// openProxyResult son los protocolos que respondieron en un ip:puerto.
+type openProxyResult struct {
+	Addr string
+	Hits []*openProxyHit
+}
+
+func (r *openProxyResult) String() string {
+	parts := make([]string, 0, len(r.Hits))
+	for _, hit := range r.Hits {
+		parts = append(parts, hit.String())
+	}
+	return fmt.Sprintf("%s - %s", r.Addr, strings.Join(parts, " "))
+}
+
+// openProxyCheckTarget contiene la comprobación directa, calculada una vez
+// antes del escaneo.
+var openProxyCheckTarget *openProxyCheck
+
+// newOpenProxyCheck pide la URL de comprobación sin proxy. Si realIP está
+// vacío, se toman como IP real las direcciones que aparecen en la respuesta.
+func newOpenProxyCheck(checkURL, realIP string, timeout time.Duration) (*openProxyCheck, error) {
+	tr := &http.Transport{DisableKeepAlives: true}
+	probeOutbound.configureTransport(tr)
+	client := &http.Client{Transport: tr, Timeout: timeout}
+	resp, err := client.Get(checkURL)
+	if err != nil {
+		return nil, fmt.Errorf("no se pudo acceder a la URL de comprobación: %w", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, openProxyCheckBodyLimit))
+
+	check := &openProxyCheck{
+		URL:        checkURL,
+		StatusCode: resp.StatusCode,
+		Server:     resp.Header.Get("Server"),
+		Headers:    make(map[string]bool),
+	}
+	if realIP != "" {
+		check.RealIPs = strings.Split(realIP, ",")
+	} else {
+		check.RealIPs = ipPattern.FindAllString(string(body), -1)
+	}
+	for _, m := range proxyHeaderPattern.FindAllStringSubmatch(string(body), -1) {
+		check.Headers[strings.ToLower(m[1])] = true
+	}
+
+	return check, nil
+}
+
+// anonymity clasifica la respuesta obtenida a través de un proxy y devuelve
+// las cabeceras de proxy encontradas.
+func (c *openProxyCheck) anonymity(body string) (string, []string) {
+	for _, ip := range c.RealIPs {
+		if ip = strings.TrimSpace(ip); ip != "" && strings.Contains(body, ip) {
+			return anonTransparent, nil
+		}
+	}
+
+	var leaked []string
+	seen := make(map[string]bool)
+	for _, m := range proxyHeaderPattern.FindAllStringSubmatch(body, -1) {
+		name := strings.ToLower(m[1])
+		if c.Headers[name] || seen[name] {
+			continue
+		}
+		seen[name] = true
+		leaked = append(leaked, name)
+	}
+	if len(leaked) > 0 {
+		return anonAnonymous, leaked
+	}
+	return anonElite, nil
+}
+
+// openProxyTransport devuelve el transporte que usa addr como proxy del
+// protocolo indicado.
+func openProxyTransport(protocol, addr string) (*http.Transport, error) {
+	tr := &http.Transport{DisableKeepAlives: true}
+
+	// El proxy a comprobar se alcanza a través de --proxy, si se indicó.
+	if protocol == proxyProtoHTTP {
+		tr.Proxy = http.ProxyURL(&url.URL{Scheme: "http", Host: addr})
+		if probeOutbound != nil {
+			tr.DialContext = probeOutbound.DialContext
+		}
+		return tr, nil
+	}
+
+	scheme := map[string]string{
+		proxyProtoConnect: "http",
+		proxyProtoSOCKS4:  "socks4",
+		proxyProtoSOCKS5:  "socks5h",
+	}[protocol]
+	var forward proxydial.Dialer
+	if probeOutbound != nil {
+		forward = probeOutbound
+	}
+	dialer, err := proxydial.FromURL(&url.URL{Scheme: scheme, Host: addr}, forward)
+	if err != nil {
+		return nil, err
+	}
+	tr.DialContext = dialer.DialContext
+	return tr, nil
+}
+
+// probeOpenProxy pide la URL de comprobación a través de addr con el
+// protocolo indicado. Devuelve nil si el proxy no funciona o si la respuesta
+// no es la de la URL de comprobación.
+func probeOpenProxy(protocol, addr string, check *openProxyCheck, timeout time.Duration) *openProxyHit {
+	tr, err := openProxyTransport(protocol, addr)
+	if err != nil {
+		return nil
+	}
+	client := &http.Client{
+		Transport: tr,
+		Timeout:   timeout,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, check.URL, nil)
+	if err != nil {
+		return nil
+	}
+
+	start := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil
+	}
+	defer resp.Body.Close()
+	latency := time.Since(start)
+
+	// Un servidor web que no es proxy responde con su propia página.
+	if resp.StatusCode != check.StatusCode || resp.Header.Get("Server") != check.Server {
+		return nil
+	}
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, openProxyCheckBodyLimit))
+	level, leaked := check.anonymity(string(body))
+
+	return &openProxyHit{
+		Protocol:  protocol,
+		Anonymity: level,
+		Latency:   latency,
+		Leaked:    leaked,
+	}
+}
+
+func scanOpenProxy(c *queuescanner.Ctx, p *queuescanner.QueueScannerScanParams) {
+	addr := p.Data.(string)
+	timeout := time.Duration(proxyFlagTimeout) * time.Second
+
+	res := &openProxyResult{Addr: addr}
+	for _, protocol := range openProxyProtocols {
+		if hit := probeOpenProxy(protocol, addr, openProxyCheckTarget, timeout); hit != nil {
+			res.Hits = append(res.Hits, hit)
+		}
+	}
+
+	if len(res.Hits) == 0 {
+		c.ScanFailed(addr, nil)
+		return
+	}
+
+	c.ScanSuccess(res, func() {
+		c.Log(colorG1.Sprint(res))
+	})
+}
+
+// openProxyRun busca proxies abiertos entre los objetivos.
+func openProxyRun() {
+	targets, err := loadTargets(proxyFlagCIDR, proxyFlagFile, proxyFlagProviderRanges)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	targets.WithPorts(splitList(proxyFlagPorts))
+
+	timeout := time.Duration(proxyFlagTimeout) * time.Second
+	openProxyCheckTarget, err = newOpenProxyCheck(proxyFlagCheckURL, proxyFlagRealIP, timeout)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	queueScanner := queuescanner.NewQueueScanner(proxyFlagThreads, scanOpenProxy)
+	queueTargets(queueScanner, targets)
+
+	queueScanner.Start(func(c *queuescanner.Ctx) {
+		results := make([]string, 0, len(c.ScanSuccessList))
+		for _, res := range c.ScanSuccessList {
+			results = append(results, res.(*openProxyResult).String())
+		}
+		writeResults(proxyFlagOutput, results)
+
+		fmt.Print("\n")
+	})
+}
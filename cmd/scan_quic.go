@@ -0,0 +1,229 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/quic-go/quic-go"
+	"github.com/quic-go/quic-go/http3"
+	"github.com/spf13/cobra"
+
+	"github.com/Pablo0303/Alama/pkg/queuescanner"
+)
+
+var quicCmd = &cobra.Command{
+	Use:   "quic",
+	Short: "Comprueba el handshake QUIC/HTTP3 de cada objetivo",
+	Long: `Intenta un handshake QUIC con cada objetivo (UDP, puerto 443 por defecto) con
+el SNI y el ALPN indicados. Muestra la latencia del handshake, la versión de
+QUIC y el ALPN negociado. Con --http3 hace además una solicitud HTTP/3.
+
+QUIC va sobre UDP, por lo que --proxy y --proxy-file no se aplican.`,
+	Run: runScanQUIC,
+}
+
+var (
+	quicFlagCIDR           string
+	quicFlagFile           string
+	quicFlagOutput         string
+	quicFlagSNI            string
+	quicFlagALPN           string
+	quicFlagPort           int
+	quicFlagHTTP3          bool
+	quicFlagHost           string
+	quicFlagPath           string
+	quicFlagVerify         string
+	quicFlagTimeout        int
+	quicFlagThreads        int
+	quicFlagProviderRanges string
+	quicFlagResolve        bool
+)
+
+func init() {
+	scanCmd.AddCommand(quicCmd)
+
+	quicCmd.Flags().StringVarP(&quicFlagCIDR, "cidr", "c", "", "Rango CIDR para escanear")
+	quicCmd.Flags().StringVarP(&quicFlagFile, "file", "f", "", "Archivo que contiene la lista de IPs/hosts para escanear")
+	addProviderRangesFlag(quicCmd, &quicFlagProviderRanges)
+	quicCmd.Flags().StringVarP(&quicFlagOutput, "output", "o", "", "Archivo de salida para guardar los resultados")
+	quicCmd.Flags().StringVar(&quicFlagSNI, "sni", "", "ServerName TLS (por defecto el objetivo)")
+	quicCmd.Flags().StringVar(&quicFlagALPN, "alpn", http3.NextProtoH3, "Protocolos ALPN a ofrecer, separados por comas")
+	quicCmd.Flags().IntVarP(&quicFlagPort, "port", "p", 443, "Puerto UDP de destino")
+	quicCmd.Flags().BoolVar(&quicFlagHTTP3, "http3", false, "Hace una solicitud HTTP/3 tras el handshake")
+	quicCmd.Flags().StringVar(&quicFlagHost, "host", "", "Cabecera Host de la solicitud HTTP/3 (por defecto el SNI)")
+	quicCmd.Flags().StringVar(&quicFlagPath, "path", "/", "Ruta de la solicitud HTTP/3")
+	quicCmd.Flags().StringVar(&quicFlagVerify, "tls-verify", tlsVerifyNone, "Verificación de certificados: none, chain (sin validar el nombre) o full")
+	quicCmd.Flags().IntVarP(&quicFlagTimeout, "timeout", "t", 5, "Tiempo de espera del handshake y de la solicitud en segundos")
+	addResolveFlag(quicCmd, &quicFlagResolve)
+	quicCmd.Flags().IntVarP(&quicFlagThreads, "threads", "T", 50, "Número de hilos concurrentes")
+}
+
+// quicProbe agrupa las opciones de la sonda QUIC.
+type quicProbe struct {
+	Port      int
+	SNI       string // vacío usa el objetivo
+	ALPN      []string
+	TLSVerify string
+	HTTP3     bool   // hace una solicitud HTTP/3 tras el handshake
+	Host      string // cabecera Host, vacía usa el SNI
+	Path      string
+	Timeout   time.Duration
+}
+
+// quicResult es el resultado del handshake con un objetivo.
+type quicResult struct {
+	Target  string
+	Latency time.Duration
+	Version string
+	ALPN    string
+	Status  string // respuesta HTTP/3, vacía sin --http3
+	Server  string
+	Err     string // error de la solicitud HTTP/3
+}
+
+func (r *quicResult) String() string {
+	s := fmt.Sprintf("%s%s - quic %s - alpn %s - %dms", r.Target, domainSuffix(r.Target), r.Version, r.ALPN, r.Latency.Milliseconds())
+	switch {
+	case r.Status != "":
+		s += " - " + r.Status
+		if r.Server != "" {
+			s += " (" + r.Server + ")"
+		}
+	case r.Err != "":
+		s += " - http3: " + r.Err
+	}
+	return s
+}
+
+// Handshake establece una conexión QUIC con target y, si se pide, hace una
+// solicitud HTTP/3 sobre ella.
+func (p *quicProbe) Handshake(target string) (*quicResult, error) {
+	sni := p.SNI
+	if sni == "" {
+		sni = target
+	}
+	tlsConfig, err := newTLSConfig(sni, p.TLSVerify)
+	if err != nil {
+		return nil, err
+	}
+	tlsConfig.NextProtos = p.ALPN
+
+	ctx, cancel := context.WithTimeout(context.Background(), p.Timeout)
+	defer cancel()
+
+	addr := net.JoinHostPort(target, strconv.Itoa(p.Port))
+	start := time.Now()
+	conn, err := quic.DialAddr(ctx, addr, tlsConfig, &quic.Config{HandshakeIdleTimeout: p.Timeout})
+	if err != nil {
+		return nil, err
+	}
+	defer conn.CloseWithError(0, "")
+
+	state := conn.ConnectionState()
+	res := &quicResult{
+		Target:  target,
+		Latency: time.Since(start),
+		Version: state.Version.String(),
+		ALPN:    state.TLS.NegotiatedProtocol,
+	}
+
+	if p.HTTP3 && res.ALPN == http3.NextProtoH3 {
+		p.request(ctx, conn, addr, sni, res)
+	}
+
+	return res, nil
+}
+
+// request hace una solicitud HTTP/3 sobre la conexión y anota el resultado.
+func (p *quicProbe) request(ctx context.Context, conn quic.Connection, addr, sni string, res *quicResult) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "https://"+addr+p.Path, nil)
+	if err != nil {
+		res.Err = err.Error()
+		return
+	}
+	req.Host = p.Host
+	if req.Host == "" {
+		req.Host = sni
+	}
+
+	client := (&http3.Transport{}).NewClientConn(conn)
+	resp, err := client.RoundTrip(req)
+	if err != nil {
+		res.Err = err.Error()
+		return
+	}
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	resp.Body.Close()
+
+	res.Status = resp.Status
+	res.Server = resp.Header.Get("Server")
+}
+
+func scanQUIC(c *queuescanner.Ctx, p *queuescanner.QueueScannerScanParams) {
+	target := p.Data.(string)
+
+	sni := quicFlagSNI
+	if sni == "" {
+		sni = resolvedDomain(target)
+	}
+	probe := &quicProbe{
+		Port:      quicFlagPort,
+		SNI:       sni,
+		TLSVerify: quicFlagVerify,
+		HTTP3:     quicFlagHTTP3,
+		Host:      quicFlagHost,
+		Path:      quicFlagPath,
+		Timeout:   time.Duration(quicFlagTimeout) * time.Second,
+	}
+	for _, alpn := range strings.Split(quicFlagALPN, ",") {
+		if alpn = strings.TrimSpace(alpn); alpn != "" {
+			probe.ALPN = append(probe.ALPN, alpn)
+		}
+	}
+
+	res, err := probe.Handshake(target)
+	if err != nil {
+		c.ScanError(target, err, nil)
+		return
+	}
+
+	c.ScanSuccess(res, func() {
+		c.Log(colorG1.Sprint(res))
+	})
+}
+
+func runScanQUIC(cmd *cobra.Command, args []string) {
+	if _, err := newTLSConfig("", quicFlagVerify); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	targets, err := loadTargets(quicFlagCIDR, quicFlagFile, quicFlagProviderRanges)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	if quicFlagResolve {
+		targets.Resolve(quicFlagThreads)
+	}
+
+	queueScanner := queuescanner.NewQueueScanner(quicFlagThreads, scanQUIC)
+	queueTargets(queueScanner, targets)
+
+	queueScanner.Start(func(c *queuescanner.Ctx) {
+		results := make([]string, 0, len(c.ScanSuccessList))
+		for _, res := range c.ScanSuccessList {
+			results = append(results, res.(*quicResult).String())
+		}
+		writeResults(quicFlagOutput, results)
+
+		fmt.Print("\n")
+	})
+}
@@ -11,6 +11,7 @@ import (
     "time"
 
     "github.com/spf13/cobra"
+    "golang.org/x/net/publicsuffix"
 
     "github.com/Pablo0303/Alama/pkg/queuescanner"
 )
@@ -18,25 +19,34 @@ import (
 var sniCmd = &cobra.Command{
     Use:   "sni",
     Short: "Scan server name indication list from file",
-    Run:   runScanSNI,
+    Long: `Prueba el handshake TLS con cada dominio de la lista como SNI.
+
+--deep N recorta cada dominio a N niveles por encima del dominio registrable,
+según la Public Suffix List incluida en el binario: con --deep 0,
+a.b.example.co.uk pasa a example.co.uk; con --deep 1, a b.example.co.uk.
+--parents añade además todos los dominios padre hasta el registrable. Los
+dominios repetidos se prueban una sola vez.`,
+    Run: runScanSNI,
 }
 
 var (
     sniFlagFilename string
+    sniFlagOutput   string
     sniFlagDeep     int
     sniFlagTimeout  int
-    sniFlagDelay    int    // Nuevo campo para el delay
-    sniFlagProxy    string // Nuevo campo para el proxy
+    sniFlagDelay    int // Nuevo campo para el delay
+    sniFlagParents  bool
 )
 
 func init() {
     scanCmd.AddCommand(sniCmd)
 
     sniCmd.Flags().StringVarP(&sniFlagFilename, "filename", "f", "", "domain list filename")
-    sniCmd.Flags().IntVarP(&sniFlagDeep, "deep", "d", 0, "deep subdomain")
+    sniCmd.Flags().StringVarP(&sniFlagOutput, "output", "o", "", "Archivo de salida para guardar los dominios que completan el handshake")
+    sniCmd.Flags().IntVarP(&sniFlagDeep, "deep", "d", 0, "Niveles por encima del dominio registrable (0 deja solo example.co.uk)")
+    sniCmd.Flags().BoolVar(&sniFlagParents, "parents", false, "Prueba también todos los dominios padre hasta el registrable")
     sniCmd.Flags().IntVar(&sniFlagTimeout, "timeout", 3, "handshake timeout")
     sniCmd.Flags().IntVarP(&sniFlagDelay, "delay", "D", 0, "delay between scans in milliseconds") // Cambiado a -D
-    sniCmd.Flags().StringVar(&sniFlagProxy, "proxy", "", "proxy and port to use") // Mantenido proxy
 
     sniCmd.MarkFlagFilename("filename")
     sniCmd.MarkFlagRequired("filename")
@@ -48,20 +58,26 @@ func scanSNI(c *queuescanner.Ctx, p *queuescanner.QueueScannerScanParams) {
     var conn net.Conn
     var err error
 
+    out := probeOutbound.pick()
     dialCount := 0
-    dialer := &net.Dialer{Timeout: 3 * time.Second} // Configura el tiempo de espera en el Dialer
     for {
         dialCount++
         if dialCount > 3 {
+            c.ScanError(domain, err, nil)
             return
         }
-        conn, err = dialer.Dial("tcp", domain+":443") // Usa el dominio como dirección
+        // Configura el tiempo de espera de la conexión, directa o por --proxy
+        ctxDial, ctxDialCancel := context.WithTimeout(context.Background(), 3*time.Second)
+        conn, err = out.DialContext(ctxDial, "tcp", domain+":443") // Usa el dominio como dirección
+        ctxDialCancel()
         if err != nil {
             if e, ok := err.(net.Error); ok && e.Timeout() {
                 c.LogReplace(p.Name, "-", "Dial Timeout")
                 continue
             }
-            c.Logf("Dial error: %s", err.Error())
+            c.ScanError(domain, err, func() {
+                c.Logf("Dial error: %s", err.Error())
+            })
             return
         }
         defer conn.Close()
@@ -78,10 +94,14 @@ func scanSNI(c *queuescanner.Ctx, p *queuescanner.QueueScannerScanParams) {
     defer ctxHandshakeCancel()
     err = tlsConn.HandshakeContext(ctxHandshake)
     if err != nil {
-        c.ScanFailed(domain, nil)
+        c.ScanError(domain, err, nil)
         return
     }
     c.ScanSuccess(domain, func() {
+        if proxy := out.Label(); proxy != "" {
+            c.Log(colorG1.Sprintf("%s (vía %s)", domain, proxy))
+            return
+        }
         c.Log(colorG1.Sprint(domain))
     })
 
@@ -91,6 +111,68 @@ func scanSNI(c *queuescanner.Ctx, p *queuescanner.QueueScannerScanParams) {
     }
 }
 
+// parseIPRange interpreta una línea con el formato ip-ip.
+func parseIPRange(line string) (net.IP, net.IP, bool) {
+    ips := strings.Split(line, "-")
+    if len(ips) != 2 {
+        return nil, nil, false
+    }
+    startIP := net.ParseIP(strings.TrimSpace(ips[0]))
+    endIP := net.ParseIP(strings.TrimSpace(ips[1]))
+    if startIP == nil || endIP == nil {
+        return nil, nil, false
+    }
+    return startIP, endIP, true
+}
+
+// sniDomains devuelve los dominios a probar para una línea de la lista. Con
+// deep >= 0 el dominio se recorta a deep niveles sobre el registrable; con
+// parents se añaden sus dominios padre hasta el registrable.
+func sniDomains(domain string, deep int, parents bool) []string {
+    domain = strings.ToLower(strings.Trim(strings.TrimSpace(domain), "."))
+    if domain == "" {
+        return nil
+    }
+    // Para la PSL 1.2.3.4 sería un dominio de 3.4: las IPs van tal cual.
+    if net.ParseIP(domain) != nil {
+        return []string{domain}
+    }
+
+    registrable, err := publicsuffix.EffectiveTLDPlusOne(domain)
+    if err != nil {
+        // Sufijos públicos y nombres de una etiqueta se prueban tal cual.
+        return []string{domain}
+    }
+
+    labels := strings.Split(domain, ".")
+    base := strings.Count(registrable, ".") + 1
+    if deep >= 0 && len(labels) > base+deep {
+        labels = labels[len(labels)-base-deep:]
+    }
+    if !parents {
+        return []string{strings.Join(labels, ".")}
+    }
+
+    domains := make([]string, 0, len(labels)-base+1)
+    for i := 0; i <= len(labels)-base; i++ {
+        domains = append(domains, strings.Join(labels[i:], "."))
+    }
+    return domains
+}
+
+// newSNIDomains es sniDomains sin los dominios que ya están en seen, que se
+// marcan como vistos.
+func newSNIDomains(seen map[string]bool, line string, deep int, parents bool) []string {
+    var domains []string
+    for _, domain := range sniDomains(line, deep, parents) {
+        if !seen[domain] {
+            seen[domain] = true
+            domains = append(domains, domain)
+        }
+    }
+    return domains
+}
+
 func runScanSNI(cmd *cobra.Command, args []string) {
     domainListFile, err := os.Open(sniFlagFilename)
     if err != nil {
@@ -101,23 +183,19 @@ func runScanSNI(cmd *cobra.Command, args []string) {
 
     queueScanner := queuescanner.NewQueueScanner(scanFlagThreads, scanSNI) // Definido aquí
 
+    // Sin --deep los dominios se prueban completos.
+    deep := -1
+    if cmd.Flags().Changed("deep") {
+        deep = sniFlagDeep
+    }
+    seen := make(map[string]bool)
+
     scanner := bufio.NewScanner(domainListFile)
     for scanner.Scan() {
         line := scanner.Text()
-        // Verifica si la línea contiene un rango de IP
-        if strings.Contains(line, "-") {
-            ips := strings.Split(line, "-")
-            if len(ips) != 2 {
-                fmt.Printf("Invalid IP range: %s\n", line)
-                continue
-            }
-            startIP := net.ParseIP(strings.TrimSpace(ips[0]))
-            endIP := net.ParseIP(strings.TrimSpace(ips[1]))
-            if startIP == nil || endIP == nil {
-                fmt.Printf("Invalid IPs: %s\n", line)
-                continue
-            }
-
+        // Verifica si la línea es un rango de IP; los dominios también
+        // pueden llevar guiones.
+        if startIP, endIP, ok := parseIPRange(line); ok {
             for ip := startIP; !ip.Equal(endIP); ip = incrementIP(ip) { // Captura el valor retornado
                 queueScanner.Add(&queuescanner.QueueScannerScanParams{
                     Name: ip.String(),
@@ -131,19 +209,22 @@ func runScanSNI(cmd *cobra.Command, args []string) {
             })
         } else {
             // Procesa una sola IP o dominio
-            domain := line
-            if sniFlagDeep > 0 {
-                domainSplit := strings.Split(domain, ".")
-                if len(domainSplit) >= sniFlagDeep {
-                    domain = strings.Join(domainSplit[len(domainSplit)-sniFlagDeep:], ".")
-                }
+            for _, domain := range newSNIDomains(seen, line, deep, sniFlagParents) {
+                queueScanner.Add(&queuescanner.QueueScannerScanParams{
+                    Name: domain,
+                    Data: domain,
+                })
             }
-            queueScanner.Add(&queuescanner.QueueScannerScanParams{
-                Name: domain,
-                Data: domain,
-            })
         }
     }
 
-    queueScanner.Start(nil)
+    queueScanner.Start(func(c *queuescanner.Ctx) {
+        results := make([]string, 0, len(c.ScanSuccessList))
+        for _, domain := range c.ScanSuccessList {
+            results = append(results, domain.(string))
+        }
+        writeResults(sniFlagOutput, results)
+
+        fmt.Print("\n")
+    })
 }
@@ -0,0 +1,51 @@
+package cmd
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSNIDomains(t *testing.T) {
+	tests := []struct {
+		domain  string
+		deep    int
+		parents bool
+		want    []string
+	}{
+		{"a.b.example.com", -1, false, []string{"a.b.example.com"}},
+		{"a.b.example.com", 0, false, []string{"example.com"}},
+		{"a.b.example.com", 1, false, []string{"b.example.com"}},
+		{"A.B.Example.COM.", -1, false, []string{"a.b.example.com"}},
+		{"a.b.example.co.uk", 0, false, []string{"example.co.uk"}},
+		{"a.b.example.co.uk", 1, false, []string{"b.example.co.uk"}},
+		{"x.y.loja.com.br", 0, false, []string{"loja.com.br"}},
+		{"x.y.loja.com.br", -1, true, []string{"x.y.loja.com.br", "y.loja.com.br", "loja.com.br"}},
+		{"a.b.example.co.uk", 1, true, []string{"b.example.co.uk", "example.co.uk"}},
+		{"example.com", 3, true, []string{"example.com"}},
+		{"co.uk", 0, true, []string{"co.uk"}},
+		{"localhost", 0, true, []string{"localhost"}},
+		{"1.2.3.4", 0, false, []string{"1.2.3.4"}},
+		{"1.2.3.4", -1, true, []string{"1.2.3.4"}},
+		{"2001:db8::1", 0, true, []string{"2001:db8::1"}},
+		{"  ", 0, false, nil},
+	}
+	for _, tt := range tests {
+		got := sniDomains(tt.domain, tt.deep, tt.parents)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("sniDomains(%q, %d, %v) = %v, se esperaba %v", tt.domain, tt.deep, tt.parents, got, tt.want)
+		}
+	}
+}
+
+func TestNewSNIDomainsDedupe(t *testing.T) {
+	seen := make(map[string]bool)
+	lines := []string{"a.example.co.uk", "b.example.co.uk", "a.example.co.uk", "example.co.uk", "1.2.3.4", "1.2.3.4"}
+	var got []string
+	for _, line := range lines {
+		got = append(got, newSNIDomains(seen, line, -1, true)...)
+	}
+	want := []string{"a.example.co.uk", "example.co.uk", "b.example.co.uk", "1.2.3.4"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("dominios = %v, se esperaba %v", got, want)
+	}
+}
@@ -1,10 +1,8 @@
 package cmd
 
 import (
-    "bufio"
     "fmt"
     "net"
-    "net/http"
     "os"
     "strings"
     "sync"
@@ -12,6 +10,8 @@ import (
 
     "github.com/spf13/cobra"
     "github.com/fatih/color"
+
+    "github.com/Pablo0303/Alama/pkg/queuescanner"
 )
 
 // udpScanCmd represents the udpScan command
@@ -22,13 +22,16 @@ var udpScanCmd = &cobra.Command{
 }
 
 var (
-    udpFlagCIDR    string
-    udpFlagFile    string
-    udpFlagOutput  string
-    udpFlagTimeout int
-    udpFlagDelay   int
-    udpFlagCount   int
-    udpFlagThreads int
+    udpFlagCIDR           string
+    udpFlagFile           string
+    udpFlagOutput         string
+    udpFlagTimeout        int
+    udpFlagDelay          int
+    udpFlagCount          int
+    udpFlagThreads        int
+    udpFlagRedirect       string
+    udpFlagMatch          matchFlags
+    udpFlagProviderRanges string
 )
 
 func init() {
@@ -41,12 +44,15 @@ func init() {
     udpScanCmd.Flags().IntVarP(&udpFlagDelay, "delay", "d", 250, "Retraso entre escaneos en milisegundos")
     udpScanCmd.Flags().IntVarP(&udpFlagCount, "count", "n", 1, "Número de intentos de escaneo por IP")
     udpScanCmd.Flags().IntVarP(&udpFlagThreads, "threads", "T", 50, "Número de hilos concurrentes")
+    addProviderRangesFlag(udpScanCmd, &udpFlagProviderRanges)
+    addMatchFlags(udpScanCmd, &udpFlagMatch)
+    addRedirectFlag(udpScanCmd, &udpFlagRedirect)
 }
 
-func udpScanHost(ip string, timeout, count int) (bool, string, string) {
+func udpScanHost(ip string, timeout, count, redirects int, filter *responseFilter) (bool, *httpResult) {
     conn, err := net.Dial("udp", fmt.Sprintf("%s:53", ip))
     if err != nil {
-        return false, "", ""
+        return false, nil
     }
     defer conn.Close()
 
@@ -54,7 +60,7 @@ func udpScanHost(ip string, timeout, count int) (bool, string, string) {
     for i := 0; i < count; i++ {
         _, err := conn.Write(message)
         if err != nil {
-            return false, "", ""
+            return false, nil
         }
 
         conn.SetReadDeadline(time.Now().Add(time.Duration(timeout) * time.Second))
@@ -62,56 +68,39 @@ func udpScanHost(ip string, timeout, count int) (bool, string, string) {
         _, err = conn.Read(buffer)
         if err == nil {
             // Realizar una solicitud HTTP para obtener la información del servidor y el código de estado
-            url := fmt.Sprintf("http://%s", ip)
-            client := &http.Client{
-                Timeout: time.Duration(timeout) * time.Second,
-            }
-            resp, err := client.Get(url)
-            if err != nil {
-                return true, "", ""
+            probe := &httpProbe{
+                Timeout:      time.Duration(timeout) * time.Second,
+                BodyLimit:    filter.BodyLimit(),
+                MaxRedirects: redirects,
+                Proxy:        probeOutbound,
             }
-            defer resp.Body.Close()
-            server := resp.Header.Get("Server")
-            status := resp.Status
-            return true, server, status
+            res, _ := probe.Fetch(ip)
+            return filter.Keep(res), res
         }
     }
-    return false, "", ""
+    return false, nil
 }
 
 func udpScanRun(cmd *cobra.Command, args []string) {
-    var ips []string
-
-    if udpFlagCIDR != "" {
-        ip, ipnet, err := net.ParseCIDR(udpFlagCIDR)
-        if err != nil {
-            fmt.Println("Rango CIDR inválido:", err)
-            return
-        }
-        for ip := ip.Mask(ipnet.Mask); ipnet.Contains(ip); incrementIP(ip) {
-            ips = append(ips, ip.String())
-        }
+    filter, err := udpFlagMatch.compile()
+    if err != nil {
+        fmt.Println(err)
+        return
+    }
+    redirects, err := parseRedirects(udpFlagRedirect)
+    if err != nil {
+        fmt.Println(err)
+        return
     }
 
-    if udpFlagFile != "" {
-        file, err := os.Open(udpFlagFile)
-        if err != nil {
-            fmt.Println("Error al abrir el archivo:", err)
-            return
-        }
-        defer file.Close()
-
-        scanner := bufio.NewScanner(file)
-        for scanner.Scan() {
-            ips = append(ips, scanner.Text())
-        }
-        if err := scanner.Err(); err != nil {
-            fmt.Println("Error al leer el archivo:", err)
-            return
-        }
+    ips, err := loadTargets(udpFlagCIDR, udpFlagFile, udpFlagProviderRanges)
+    if err != nil {
+        fmt.Println(err)
+        return
     }
 
-    total := len(ips)
+    total := ips.Len()
+    tracker := queuescanner.NewCtx(total)
     found := 0
     var mu sync.Mutex
     var wg sync.WaitGroup
@@ -119,7 +108,7 @@ func udpScanRun(cmd *cobra.Command, args []string) {
     green := color.New(color.FgGreen).SprintFunc()
     results := make([]string, 0)
 
-    for i, ip := range ips {
+    ips.Each(func(i int, ip string) {
         wg.Add(1)
         sem <- struct{}{}
         go func(i int, ip string) {
@@ -127,11 +116,16 @@ func udpScanRun(cmd *cobra.Command, args []string) {
             defer func() { <-sem }()
             progress := float64(i+1) / float64(total) * 100
 
-            success, server, status := udpScanHost(ip, udpFlagTimeout, udpFlagCount)
+            var success bool
+            var res *httpResult
+            tracker.Track(func() (bool, error) {
+                success, res = udpScanHost(ip, udpFlagTimeout, udpFlagCount, redirects, filter)
+                return success, nil
+            })
             if success {
                 mu.Lock()
                 found++
-                result := fmt.Sprintf("%s - %s - %s", ip, server, status)
+                result := formatHTTPHit(ip, res)
                 results = append(results, result)
                 fmt.Printf("\n%s\n", green(result)) // Mostrar IP, servidor y estado en color verde en una línea independiente
                 mu.Unlock()
@@ -146,7 +140,7 @@ func udpScanRun(cmd *cobra.Command, args []string) {
                 time.Sleep(time.Duration(udpFlagDelay) * time.Millisecond)
             }
         }(i, ip)
-    }
+    })
     wg.Wait()
 
     // Asegurarse de que la línea final se muestre correctamente
@@ -0,0 +1,400 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/Pablo0303/Alama/pkg/queuescanner"
+)
+
+var wsCmd = &cobra.Command{
+	Use:   "ws",
+	Short: "Comprueba el handshake WebSocket (RFC 6455) de cada objetivo",
+	Long: `Envía un handshake WebSocket sobre ws o wss y valida Sec-WebSocket-Accept.
+Cada objetivo se clasifica como:
+
+  upgrade     101 con la clave de aceptación correcta
+  bad-accept  101 con una clave de aceptación incorrecta o sin Upgrade: websocket
+  refused     el servidor respondió sin cambiar de protocolo
+
+Con --ping se envía además un frame ping y se espera el pong, para confirmar
+que el túnel deja pasar datos.`,
+	Run: runScanWS,
+}
+
+var (
+	wsFlagCIDR           string
+	wsFlagFile           string
+	wsFlagOutput         string
+	wsFlagScheme         string
+	wsFlagHost           string
+	wsFlagPath           string
+	wsFlagSNI            string
+	wsFlagPort           int
+	wsFlagPing           bool
+	wsFlagTimeout        int
+	wsFlagThreads        int
+	wsFlagProviderRanges string
+	wsFlagResolve        bool
+)
+
+// Clases de respuesta del handshake WebSocket.
+const (
+	wsClassUpgrade   = "upgrade"
+	wsClassBadAccept = "bad-accept"
+	wsClassRefused   = "refused"
+)
+
+// wsGUID es el valor fijo con el que se calcula Sec-WebSocket-Accept.
+const wsGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// Códigos de operación de los frames WebSocket que se usan.
+const (
+	wsOpClose = 0x8
+	wsOpPing  = 0x9
+	wsOpPong  = 0xA
+)
+
+// wsFrameLimit es el tamaño máximo de un frame de datos que se descarta
+// mientras se espera el pong.
+const wsFrameLimit = 64 << 10
+
+func init() {
+	scanCmd.AddCommand(wsCmd)
+
+	wsCmd.Flags().StringVarP(&wsFlagCIDR, "cidr", "c", "", "Rango CIDR para escanear")
+	wsCmd.Flags().StringVarP(&wsFlagFile, "file", "f", "", "Archivo que contiene la lista de IPs/hosts para escanear")
+	addProviderRangesFlag(wsCmd, &wsFlagProviderRanges)
+	wsCmd.Flags().StringVarP(&wsFlagOutput, "output", "o", "", "Archivo de salida para guardar los resultados")
+	wsCmd.Flags().StringVar(&wsFlagScheme, "scheme", "ws", "Esquema: ws o wss")
+	wsCmd.Flags().StringVar(&wsFlagHost, "host", "", "Cabecera Host (por defecto el objetivo)")
+	wsCmd.Flags().StringVar(&wsFlagPath, "path", "/", "Ruta del handshake")
+	wsCmd.Flags().StringVar(&wsFlagSNI, "sni", "", "ServerName TLS con wss (por defecto el valor de --host)")
+	wsCmd.Flags().IntVarP(&wsFlagPort, "port", "p", 0, "Puerto de destino (0 usa 80 para ws y 443 para wss)")
+	wsCmd.Flags().BoolVar(&wsFlagPing, "ping", false, "Envía un ping tras el handshake y exige el pong")
+	wsCmd.Flags().IntVarP(&wsFlagTimeout, "timeout", "t", 5, "Tiempo de espera de la conexión en segundos")
+	addResolveFlag(wsCmd, &wsFlagResolve)
+	wsCmd.Flags().IntVarP(&wsFlagThreads, "threads", "T", 50, "Número de hilos concurrentes")
+}
+
+// wsResult es el resultado del handshake con un objetivo.
+type wsResult struct {
+	Target string
+	Class  string
+	Status string
+	Server string
+	Ping   string // "ok" o el motivo del fallo; vacío sin --ping
+	Proxy  string // proxy de --proxy-file por el que salió
+}
+
+func (r *wsResult) String() string {
+	s := fmt.Sprintf("%s%s - %s - %s", r.Target, domainSuffix(r.Target), r.Class, r.Status)
+	if r.Server != "" {
+		s += " (" + r.Server + ")"
+	}
+	if r.Ping != "" {
+		s += " - ping " + r.Ping
+	}
+	if r.Proxy != "" {
+		s += " (vía " + r.Proxy + ")"
+	}
+	return s
+}
+
+// ok indica si el objetivo es un WebSocket utilizable.
+func (r *wsResult) ok() bool {
+	return r.Class == wsClassUpgrade && (r.Ping == "" || r.Ping == "ok")
+}
+
+// wsAcceptKey calcula el Sec-WebSocket-Accept esperado para la clave.
+func wsAcceptKey(key string) string {
+	sum := sha1.Sum([]byte(key + wsGUID))
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// wsNewKey genera un Sec-WebSocket-Key aleatorio.
+func wsNewKey() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(b), nil
+}
+
+// wsWriteFrame envía un frame de control enmascarado, como exige la RFC a los
+// clientes.
+func wsWriteFrame(w io.Writer, opcode byte, payload []byte) error {
+	var mask [4]byte
+	if _, err := rand.Read(mask[:]); err != nil {
+		return err
+	}
+
+	frame := make([]byte, 0, 6+len(payload))
+	frame = append(frame, 0x80|opcode, 0x80|byte(len(payload)))
+	frame = append(frame, mask[:]...)
+	for i, b := range payload {
+		frame = append(frame, b^mask[i%4])
+	}
+
+	_, err := w.Write(frame)
+	return err
+}
+
+// wsReadFrame lee un frame y devuelve su código de operación y su contenido.
+func wsReadFrame(r *bufio.Reader) (byte, []byte, error) {
+	var head [2]byte
+	if _, err := io.ReadFull(r, head[:]); err != nil {
+		return 0, nil, err
+	}
+	opcode := head[0] & 0x0F
+
+	length := uint64(head[1] & 0x7F)
+	switch length {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(r, ext[:]); err != nil {
+			return 0, nil, err
+		}
+		length = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(r, ext[:]); err != nil {
+			return 0, nil, err
+		}
+		length = binary.BigEndian.Uint64(ext[:])
+	}
+	if length > wsFrameLimit {
+		return 0, nil, errors.New("frame demasiado grande")
+	}
+
+	var mask []byte
+	if head[1]&0x80 != 0 {
+		mask = make([]byte, 4)
+		if _, err := io.ReadFull(r, mask); err != nil {
+			return 0, nil, err
+		}
+	}
+
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return 0, nil, err
+	}
+	if mask != nil {
+		for i := range payload {
+			payload[i] ^= mask[i%4]
+		}
+	}
+
+	return opcode, payload, nil
+}
+
+// wsPing envía un ping y espera el pong con el mismo contenido, descartando
+// los frames de datos que lleguen antes.
+func wsPing(conn net.Conn, r *bufio.Reader) string {
+	token := []byte(randomToken(8))
+	if err := wsWriteFrame(conn, wsOpPing, token); err != nil {
+		return "sin respuesta"
+	}
+
+	for {
+		opcode, payload, err := wsReadFrame(r)
+		if err != nil {
+			return "sin respuesta"
+		}
+		switch opcode {
+		case wsOpPong:
+			if string(payload) == string(token) {
+				return "ok"
+			}
+			return "pong incorrecto"
+		case wsOpClose:
+			return "cerrado"
+		}
+	}
+}
+
+// wsProbe agrupa las opciones del handshake WebSocket.
+type wsProbe struct {
+	Scheme  string // ws o wss
+	Port    int    // 0 usa el puerto por defecto del esquema
+	Host    string // cabecera Host, vacía usa el objetivo
+	Path    string
+	SNI     string // ServerName TLS, vacío usa Host
+	Ping    bool   // comprueba el túnel con un ping tras el handshake
+	Timeout time.Duration
+	Proxy   *outbound
+}
+
+// Handshake hace el handshake WebSocket con target. Devuelve error si no se
+// obtuvo respuesta HTTP.
+func (p *wsProbe) Handshake(target string) (*wsResult, error) {
+	out := p.Proxy.pick()
+	tlsOn := p.Scheme == "wss"
+	port := p.Port
+	if port == 0 {
+		port = 80
+		if tlsOn {
+			port = 443
+		}
+	}
+	host := p.Host
+	if host == "" {
+		host = target
+	}
+	path := p.Path
+	if path == "" {
+		path = "/"
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), p.Timeout)
+	defer cancel()
+	conn, err := out.DialContext(ctx, "tcp", net.JoinHostPort(target, strconv.Itoa(port)))
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(p.Timeout))
+
+	if tlsOn {
+		sni := p.SNI
+		if sni == "" {
+			sni = host
+		}
+		tlsConn := tls.Client(conn, &tls.Config{
+			ServerName:         sni,
+			InsecureSkipVerify: true,
+			NextProtos:         []string{"http/1.1"},
+		})
+		if err := tlsConn.Handshake(); err != nil {
+			return nil, err
+		}
+		conn = tlsConn
+	}
+
+	key, err := wsNewKey()
+	if err != nil {
+		return nil, err
+	}
+	req := "GET " + path + " HTTP/1.1\r\n" +
+		"Host: " + host + "\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: Upgrade\r\n" +
+		"Sec-WebSocket-Key: " + key + "\r\n" +
+		"Sec-WebSocket-Version: 13\r\n\r\n"
+	if _, err := io.WriteString(conn, req); err != nil {
+		return nil, err
+	}
+
+	r := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(r, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	res := &wsResult{
+		Target: target,
+		Status: resp.Status,
+		Server: resp.Header.Get("Server"),
+		Proxy:  out.Label(),
+	}
+	switch {
+	case resp.StatusCode != http.StatusSwitchingProtocols:
+		res.Class = wsClassRefused
+	case !strings.EqualFold(resp.Header.Get("Upgrade"), "websocket") ||
+		resp.Header.Get("Sec-WebSocket-Accept") != wsAcceptKey(key):
+		res.Class = wsClassBadAccept
+	default:
+		res.Class = wsClassUpgrade
+		if p.Ping {
+			res.Ping = wsPing(conn, r)
+		}
+	}
+
+	return res, nil
+}
+
+func scanWS(c *queuescanner.Ctx, p *queuescanner.QueueScannerScanParams) {
+	target := p.Data.(string)
+	host := wsFlagHost
+	if host == "" {
+		host = resolvedDomain(target)
+	}
+	probe := &wsProbe{
+		Scheme:  wsFlagScheme,
+		Port:    wsFlagPort,
+		Host:    host,
+		Path:    wsFlagPath,
+		SNI:     wsFlagSNI,
+		Ping:    wsFlagPing,
+		Timeout: time.Duration(wsFlagTimeout) * time.Second,
+		Proxy:   probeOutbound,
+	}
+
+	res, err := probe.Handshake(target)
+	if err != nil {
+		c.ScanError(target, err, nil)
+		return
+	}
+
+	switch {
+	case res.ok():
+		c.ScanSuccess(res, func() {
+			c.Log(colorG1.Sprint(res))
+		})
+	case res.Class == wsClassRefused:
+		c.ScanFailed(res, nil)
+	default:
+		c.ScanFailed(res, func() {
+			c.Log(colorY1.Sprint(res))
+		})
+	}
+}
+
+func runScanWS(cmd *cobra.Command, args []string) {
+	if wsFlagScheme != "ws" && wsFlagScheme != "wss" {
+		fmt.Println("Esquema inválido:", wsFlagScheme)
+		os.Exit(1)
+	}
+
+	targets, err := loadTargets(wsFlagCIDR, wsFlagFile, wsFlagProviderRanges)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	if wsFlagResolve {
+		targets.Resolve(wsFlagThreads)
+	}
+
+	queueScanner := queuescanner.NewQueueScanner(wsFlagThreads, scanWS)
+	queueTargets(queueScanner, targets)
+
+	queueScanner.Start(func(c *queuescanner.Ctx) {
+		var results []string
+		for _, list := range [][]interface{}{c.ScanSuccessList, c.ScanFailedList} {
+			for _, res := range list {
+				if res, ok := res.(*wsResult); ok {
+					results = append(results, res.String())
+				}
+			}
+		}
+		writeResults(wsFlagOutput, results)
+
+		fmt.Print("\n")
+	})
+}
@@ -0,0 +1,685 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var serveCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Servidor HTTP local para lanzar y seguir escaneos desde otras herramientas",
+	Long: `Expone una API REST para lanzar cualquier comando de Alama, con las mismas
+banderas que en la línea de comandos, y seguir su progreso. Todas las
+solicitudes necesitan el token en la cabecera Authorization: Bearer <token> o
+en el parámetro token; si no se indica con --token se genera uno al arrancar.
+
+  POST   /api/jobs               lanza un escaneo:
+                                 {"command": "scan sni", "flags": {"timeout": 3},
+                                  "targets": ["example.com"], "args": []}
+  GET    /api/jobs               lista los escaneos
+  GET    /api/jobs/{id}          estado y progreso de un escaneo
+  GET    /api/jobs/{id}/events   eventos SSE: line (cada línea que imprime el
+                                 escaneo), hit, progress y end
+  GET    /api/jobs/{id}/hits     resultados con objetivo, estado, latencia y
+                                 proveedor; ?format=csv los da en CSV
+  GET    /api/jobs/{id}/output   descarga el archivo de resultados
+  GET    /api/commands           comandos que se pueden lanzar y sus banderas
+  DELETE /api/jobs/{id}          cancela el escaneo
+
+targets se guarda en un archivo y se pasa con --file (o --filename). Como
+mucho se ejecutan --max-jobs escaneos a la vez; el resto espera en cola.
+
+En / se sirve un panel web para lanzar escaneos, seguir su progreso y
+exportar los resultados; la dirección con el token se muestra al arrancar.`,
+	Args: cobra.NoArgs,
+	// Cada escaneo prepara sus propios proxies y DNS; aquí solo se aplica la
+	// configuración a las banderas de serve.
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		_, err := applyConfig(cmd)
+		cobra.CheckErr(err)
+	},
+	Run: runServe,
+}
+
+var (
+	serveFlagListen  string
+	serveFlagToken   string
+	serveFlagMaxJobs int
+	serveFlagDir     string
+)
+
+func init() {
+	rootCmd.AddCommand(serveCmd)
+
+	serveCmd.Flags().StringVar(&serveFlagListen, "listen", "127.0.0.1:8787", "Dirección en la que escucha el servidor")
+	serveCmd.Flags().StringVar(&serveFlagToken, "token", "", "Token de acceso a la API (por defecto se genera uno)")
+	serveCmd.Flags().IntVar(&serveFlagMaxJobs, "max-jobs", 2, "Escaneos que se ejecutan a la vez")
+	serveCmd.Flags().StringVar(&serveFlagDir, "dir", "alama-serve", "Directorio para los objetivos y resultados de los escaneos")
+}
+
+// Estados de un escaneo de serve.
+const (
+	serveQueued   = "queued"
+	serveRunning  = "running"
+	serveDone     = "done"
+	serveFailed   = "failed"
+	serveCanceled = "canceled"
+)
+
+// Formatos de la línea de progreso de queuescanner, de logReplace y de
+// httping.
+var (
+	progressQueueRe   = regexp.MustCompile(`^\s*([\d.]+)% - C: (\d+) / (\d+) - S: (\d+) - F: (\d+)`)
+	progressReplaceRe = regexp.MustCompile(`^Escaneando: .* F:(\d+) (\d+)/(\d+) ([\d.]+)%`)
+	progressHTTPingRe = regexp.MustCompile(`^Escaneando (\d+)/(\d+) \(F:(\d+)\) ([\d.]+)%`)
+	ansiRe            = regexp.MustCompile(`\x1b\[[0-9;]*[A-Za-z]`)
+)
+
+// serveFileFlags son las banderas que leen o escriben archivos con la ruta
+// indicada. Desde la API no se aceptan: los objetivos llegan en targets y
+// serve decide dónde van los resultados.
+var serveFileFlags = map[string]bool{
+	"config":       true,
+	"profile":      true,
+	"metrics":      true,
+	"metrics-file": true,
+	"proxy-file":   true,
+	"file":         true,
+	"filename":     true,
+	"output":       true,
+	"failed":       true,
+	"wordlist":     true,
+	"stages-file":  true,
+}
+
+// serveRequest es el cuerpo de POST /api/jobs.
+type serveRequest struct {
+	Command string                 `json:"command"`
+	Flags   map[string]interface{} `json:"flags"`
+	Args    []string               `json:"args"`
+	Targets []string               `json:"targets"`
+}
+
+// serveProgress es el avance de un escaneo según su línea de progreso.
+type serveProgress struct {
+	Percent float64 `json:"percent"`
+	Done    int     `json:"done"`
+	Total   int     `json:"total"`
+	Found   int     `json:"found"`
+	Failed  int     `json:"failed"`
+}
+
+// parseProgress interpreta una línea de progreso de los escaneos.
+func parseProgress(line string) (serveProgress, bool) {
+	if m := progressQueueRe.FindStringSubmatch(line); m != nil {
+		var p serveProgress
+		p.Percent, _ = strconv.ParseFloat(m[1], 64)
+		p.Done, _ = strconv.Atoi(m[2])
+		p.Total, _ = strconv.Atoi(m[3])
+		p.Found, _ = strconv.Atoi(m[4])
+		p.Failed, _ = strconv.Atoi(m[5])
+		return p, true
+	}
+	if m := progressReplaceRe.FindStringSubmatch(line); m != nil {
+		var p serveProgress
+		p.Found, _ = strconv.Atoi(m[1])
+		p.Done, _ = strconv.Atoi(m[2])
+		p.Total, _ = strconv.Atoi(m[3])
+		p.Percent, _ = strconv.ParseFloat(m[4], 64)
+		return p, true
+	}
+	if m := progressHTTPingRe.FindStringSubmatch(line); m != nil {
+		var p serveProgress
+		p.Done, _ = strconv.Atoi(m[1])
+		p.Total, _ = strconv.Atoi(m[2])
+		p.Found, _ = strconv.Atoi(m[3])
+		p.Percent, _ = strconv.ParseFloat(m[4], 64)
+		return p, true
+	}
+	return serveProgress{}, false
+}
+
+// serveJob es un escaneo lanzado por la API.
+type serveJob struct {
+	id      string
+	command string
+	args    []string
+	output  string
+	metrics string // métricas que guarda el proceso con --metrics-file
+	created time.Time
+
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mu       sync.Mutex
+	status   string
+	started  time.Time
+	finished time.Time
+	progress serveProgress
+	lines    []string
+	hits     []*serveHit
+	results  int
+	exit     int
+	err      string
+	changed  chan struct{} // se cierra y se renueva en cada cambio
+}
+
+// serveJobInfo es el estado de un escaneo en las respuestas de la API.
+type serveJobInfo struct {
+	ID       string        `json:"id"`
+	Command  string        `json:"command"`
+	Args     []string      `json:"args"`
+	Status   string        `json:"status"`
+	Progress serveProgress `json:"progress"`
+	Lines    int           `json:"lines"`
+	Hits     int           `json:"hits"`
+	Results  int           `json:"results"`
+	Exit     int           `json:"exit"`
+	Error    string        `json:"error,omitempty"`
+	Created  time.Time     `json:"created"`
+	Started  *time.Time    `json:"started,omitempty"`
+	Finished *time.Time    `json:"finished,omitempty"`
+}
+
+// Info devuelve el estado actual del escaneo.
+func (j *serveJob) Info() serveJobInfo {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	info := serveJobInfo{
+		ID:       j.id,
+		Command:  j.command,
+		Args:     j.args,
+		Status:   j.status,
+		Progress: j.progress,
+		Lines:    len(j.lines),
+		Hits:     len(j.hits),
+		Results:  j.results,
+		Exit:     j.exit,
+		Error:    j.err,
+		Created:  j.created,
+	}
+	if !j.started.IsZero() {
+		started := j.started
+		info.Started = &started
+	}
+	if !j.finished.IsZero() {
+		finished := j.finished
+		info.Finished = &finished
+	}
+	return info
+}
+
+// update modifica el escaneo y avisa a quien lo sigue.
+func (j *serveJob) update(fn func()) {
+	j.mu.Lock()
+	fn()
+	close(j.changed)
+	j.changed = make(chan struct{})
+	j.mu.Unlock()
+}
+
+// ended indica si el escaneo terminó.
+func (j *serveJob) ended() bool {
+	switch j.status {
+	case serveDone, serveFailed, serveCanceled:
+		return true
+	}
+	return false
+}
+
+// finish marca el escaneo como terminado según el error del proceso.
+func (j *serveJob) finish(err error) {
+	results := len(readJobResults(j.output))
+	j.update(func() {
+		j.finished = time.Now()
+		j.results = results
+		switch {
+		case j.ctx.Err() != nil:
+			j.status = serveCanceled
+		case err != nil:
+			j.status = serveFailed
+			j.err = err.Error()
+			var exitErr *exec.ExitError
+			if errors.As(err, &exitErr) {
+				j.exit = exitErr.ExitCode()
+			}
+		default:
+			j.status = serveDone
+		}
+	})
+}
+
+// run ejecuta el escaneo en otro proceso y recoge su salida.
+func (j *serveJob) run(exe string) {
+	cmd := exec.CommandContext(j.ctx, exe, j.args...)
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		j.finish(err)
+		return
+	}
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Start(); err != nil {
+		j.finish(err)
+		return
+	}
+	j.update(func() {
+		j.status = serveRunning
+		j.started = time.Now()
+	})
+
+	scanner := bufio.NewScanner(stdout)
+	scanner.Split(scanTerminalLines)
+	for scanner.Scan() {
+		line := strings.TrimSpace(ansiRe.ReplaceAllString(scanner.Text(), ""))
+		if line == "" {
+			continue
+		}
+		if p, ok := parseProgress(line); ok {
+			j.update(func() { j.progress = p })
+			continue
+		}
+		hit, isHit := parseHit(line)
+		j.update(func() {
+			j.lines = append(j.lines, line)
+			if isHit {
+				j.hits = append(j.hits, hit)
+			}
+		})
+	}
+
+	err = cmd.Wait()
+	if err != nil && j.ctx.Err() == nil {
+		// El último mensaje de error del proceso explica mejor el fallo.
+		if msg := lastLine(stderr.String()); msg != "" {
+			err = fmt.Errorf("%w: %s", err, msg)
+		}
+	}
+	j.finish(err)
+}
+
+// scanTerminalLines separa la salida en líneas terminadas en \n o en \r,
+// que es como se reescribe la línea de progreso.
+func scanTerminalLines(data []byte, atEOF bool) (int, []byte, error) {
+	if i := bytes.IndexAny(data, "\r\n"); i >= 0 {
+		return i + 1, data[:i], nil
+	}
+	if atEOF && len(data) > 0 {
+		return len(data), data, nil
+	}
+	return 0, nil, nil
+}
+
+// lastLine devuelve la última línea no vacía de s.
+func lastLine(s string) string {
+	lines := strings.Split(strings.TrimSpace(s), "\n")
+	return strings.TrimSpace(lines[len(lines)-1])
+}
+
+// scanServer guarda los escaneos lanzados y limita cuántos se ejecutan a la
+// vez.
+type scanServer struct {
+	token  string
+	dir    string
+	exe    string
+	global []string
+	slots  chan struct{}
+
+	mu   sync.Mutex
+	jobs map[string]*serveJob
+	list []*serveJob
+	next int
+}
+
+// newServeToken genera un token aleatorio.
+func newServeToken() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// Handler devuelve las rutas de la API, protegidas con el token, y el panel
+// web, que pide el token al abrirlo.
+func (s *scanServer) Handler() http.Handler {
+	api := http.NewServeMux()
+	api.HandleFunc("GET /api/commands", s.handleCommands)
+	api.HandleFunc("POST /api/jobs", s.handleCreate)
+	api.HandleFunc("GET /api/jobs", s.handleList)
+	api.HandleFunc("GET /api/jobs/{id}", s.handleGet)
+	api.HandleFunc("DELETE /api/jobs/{id}", s.handleCancel)
+	api.HandleFunc("GET /api/jobs/{id}/events", s.handleEvents)
+	api.HandleFunc("GET /api/jobs/{id}/hits", s.handleHits)
+	api.HandleFunc("GET /api/jobs/{id}/output", s.handleOutput)
+
+	mux := http.NewServeMux()
+	mux.Handle("/api/", s.auth(api))
+	mux.Handle("GET /metrics", s.auth(http.HandlerFunc(s.handleMetrics)))
+	mux.Handle("/", webHandler())
+	return mux
+}
+
+// auth rechaza las solicitudes sin el token.
+func (s *scanServer) auth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := r.URL.Query().Get("token")
+		if auth := r.Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+			token = strings.TrimPrefix(auth, "Bearer ")
+		}
+		if subtle.ConstantTimeCompare([]byte(token), []byte(s.token)) != 1 {
+			writeJSONError(w, http.StatusUnauthorized, "token inválido")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeJSONError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, map[string]string{"error": msg})
+}
+
+// job devuelve el escaneo de la ruta o responde 404.
+func (s *scanServer) job(w http.ResponseWriter, r *http.Request) *serveJob {
+	s.mu.Lock()
+	j := s.jobs[r.PathValue("id")]
+	s.mu.Unlock()
+	if j == nil {
+		writeJSONError(w, http.StatusNotFound, "escaneo desconocido")
+	}
+	return j
+}
+
+// create valida la solicitud y prepara el escaneo con la misma comprobación
+// de comandos y banderas que run.
+func (s *scanServer) create(req *serveRequest) (*serveJob, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for name := range req.Flags {
+		if serveFileFlags[name] {
+			return nil, fmt.Errorf("--%s no se puede indicar desde la API", name)
+		}
+	}
+	// Tras --output una bandera en args la sustituiría.
+	for _, arg := range req.Args {
+		if strings.HasPrefix(arg, "-") {
+			return nil, fmt.Errorf("args solo admite argumentos, no banderas: %s", arg)
+		}
+	}
+
+	id := strconv.Itoa(s.next + 1)
+	dir := filepath.Join(s.dir, id)
+	step := &jobStep{Name: id, Command: req.Command, Flags: req.Flags, Args: req.Args}
+	j := &job{Name: id, Output: dir, Steps: []*jobStep{step}}
+	if err := j.validate(); err != nil {
+		return nil, errors.New(strings.TrimPrefix(err.Error(), id+": "))
+	}
+
+	input := ""
+	if len(req.Targets) > 0 {
+		step.inputFlag = jobInputFlag(step.cmd)
+		if step.inputFlag == "" {
+			return nil, fmt.Errorf("%s no lee objetivos de un archivo", req.Command)
+		}
+		if _, ok := step.Flags[step.inputFlag]; ok {
+			return nil, fmt.Errorf("targets y --%s no se pueden combinar", step.inputFlag)
+		}
+		input = filepath.Join(dir, "objetivos.lst")
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	if input != "" {
+		if err := os.WriteFile(input, []byte(strings.Join(req.Targets, "\n")), 0644); err != nil {
+			return nil, err
+		}
+	}
+
+	output := filepath.Join(dir, "resultados.lst")
+	metrics := filepath.Join(dir, "metrics.json")
+	global := append([]string{"--metrics-file=" + metrics}, s.global...)
+	sj := &serveJob{
+		id:      id,
+		command: req.Command,
+		args:    step.args(j, global, input, output),
+		output:  output,
+		metrics: metrics,
+		created: time.Now(),
+		status:  serveQueued,
+		changed: make(chan struct{}),
+	}
+	sj.ctx, sj.cancel = context.WithCancel(context.Background())
+	s.next++
+	s.jobs[id] = sj
+	s.list = append(s.list, sj)
+	return sj, nil
+}
+
+// start ejecuta el escaneo cuando hay un hueco libre.
+func (s *scanServer) start(j *serveJob) {
+	go func() {
+		select {
+		case s.slots <- struct{}{}:
+		case <-j.ctx.Done():
+			j.finish(nil)
+			return
+		}
+		defer func() { <-s.slots }()
+		if j.ctx.Err() != nil {
+			j.finish(nil)
+			return
+		}
+		j.run(s.exe)
+	}()
+}
+
+func (s *scanServer) handleCreate(w http.ResponseWriter, r *http.Request) {
+	var req serveRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "JSON inválido: "+err.Error())
+		return
+	}
+	j, err := s.create(&req)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	s.start(j)
+
+	w.Header().Set("Location", "/api/jobs/"+j.id)
+	writeJSON(w, http.StatusCreated, j.Info())
+}
+
+func (s *scanServer) handleList(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	list := append([]*serveJob(nil), s.list...)
+	s.mu.Unlock()
+
+	infos := make([]serveJobInfo, 0, len(list))
+	for _, j := range list {
+		infos = append(infos, j.Info())
+	}
+	writeJSON(w, http.StatusOK, infos)
+}
+
+func (s *scanServer) handleGet(w http.ResponseWriter, r *http.Request) {
+	if j := s.job(w, r); j != nil {
+		writeJSON(w, http.StatusOK, j.Info())
+	}
+}
+
+func (s *scanServer) handleCancel(w http.ResponseWriter, r *http.Request) {
+	j := s.job(w, r)
+	if j == nil {
+		return
+	}
+	j.mu.Lock()
+	ended := j.ended()
+	j.mu.Unlock()
+	if ended {
+		writeJSONError(w, http.StatusConflict, "el escaneo ya terminó")
+		return
+	}
+	j.cancel()
+	writeJSON(w, http.StatusAccepted, j.Info())
+}
+
+// handleEvents envía por SSE las líneas del escaneo desde el principio, su
+// progreso y, al terminar, su estado final.
+func (s *scanServer) handleEvents(w http.ResponseWriter, r *http.Request) {
+	j := s.job(w, r)
+	if j == nil {
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeJSONError(w, http.StatusInternalServerError, "el servidor no admite SSE")
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+
+	sent, sentHits := 0, 0
+	var last serveProgress
+	for {
+		j.mu.Lock()
+		lines := j.lines[sent:]
+		hits := j.hits[sentHits:]
+		progress := j.progress
+		ended := j.ended()
+		changed := j.changed
+		j.mu.Unlock()
+
+		for _, line := range lines {
+			fmt.Fprintf(w, "event: line\ndata: %s\n\n", line)
+		}
+		sent += len(lines)
+		for _, hit := range hits {
+			data, _ := json.Marshal(hit)
+			fmt.Fprintf(w, "event: hit\ndata: %s\n\n", data)
+		}
+		sentHits += len(hits)
+		if progress != last {
+			data, _ := json.Marshal(progress)
+			fmt.Fprintf(w, "event: progress\ndata: %s\n\n", data)
+			last = progress
+		}
+		if ended {
+			data, _ := json.Marshal(j.Info())
+			fmt.Fprintf(w, "event: end\ndata: %s\n\n", data)
+			flusher.Flush()
+			return
+		}
+		flusher.Flush()
+
+		select {
+		case <-changed:
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+func (s *scanServer) handleOutput(w http.ResponseWriter, r *http.Request) {
+	j := s.job(w, r)
+	if j == nil {
+		return
+	}
+	if _, err := os.Stat(j.output); err != nil {
+		writeJSONError(w, http.StatusNotFound, "el escaneo no ha guardado resultados")
+		return
+	}
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", "alama-"+j.id+".lst"))
+	http.ServeFile(w, r, j.output)
+}
+
+// cancelAll cancela todos los escaneos pendientes y en curso.
+func (s *scanServer) cancelAll() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, j := range s.list {
+		j.cancel()
+	}
+}
+
+func runServe(cmd *cobra.Command, args []string) {
+	if serveFlagMaxJobs < 1 {
+		fmt.Println("--max-jobs debe ser al menos 1")
+		os.Exit(1)
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	token := serveFlagToken
+	if token == "" {
+		token = newServeToken()
+		fmt.Fprintln(os.Stderr, "Token:", token)
+	}
+	srv := &scanServer{
+		token:  token,
+		dir:    serveFlagDir,
+		exe:    exe,
+		global: serveGlobalArgs(),
+		slots:  make(chan struct{}, serveFlagMaxJobs),
+		jobs:   make(map[string]*serveJob),
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	listener, err := net.Listen("tcp", serveFlagListen)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	// Sin WriteTimeout: los eventos de un escaneo se envían mientras dure.
+	server := &http.Server{
+		Handler:           srv.Handler(),
+		ReadHeaderTimeout: 10 * time.Second,
+		IdleTimeout:       2 * time.Minute,
+		BaseContext:       func(net.Listener) context.Context { return ctx },
+	}
+	go func() {
+		<-ctx.Done()
+		srv.cancelAll()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(shutdownCtx)
+	}()
+
+	fmt.Fprintf(os.Stderr, "Escuchando en http://%s\n", listener.Addr())
+	fmt.Fprintf(os.Stderr, "Panel: http://%s/#token=%s\n", listener.Addr(), token)
+	if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+}
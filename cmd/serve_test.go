@@ -0,0 +1,78 @@
+package cmd
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestServeCreateRejectsPaths(t *testing.T) {
+	s := &scanServer{dir: t.TempDir(), jobs: make(map[string]*serveJob)}
+
+	tests := []struct {
+		name string
+		req  serveRequest
+	}{
+		{"output en args", serveRequest{Command: "scan sni", Args: []string{"--output=/tmp/x"}}},
+		{"config en args", serveRequest{Command: "scan sni", Args: []string{"--config=/etc/passwd"}}},
+		{"bandera corta en args", serveRequest{Command: "scan sni", Args: []string{"-o", "/tmp/x"}}},
+		{"config en flags", serveRequest{Command: "scan sni", Flags: map[string]interface{}{"config": "/etc/passwd"}}},
+		{"metrics-file en flags", serveRequest{Command: "scan sni", Flags: map[string]interface{}{"metrics-file": "/tmp/x"}}},
+		{"proxy-file en flags", serveRequest{Command: "httping", Flags: map[string]interface{}{"proxy-file": "/etc/passwd"}}},
+		{"entrada en flags", serveRequest{Command: "scan sni", Flags: map[string]interface{}{"filename": "/etc/passwd"}}},
+		{"output en flags", serveRequest{Command: "httping", Flags: map[string]interface{}{"output": "/tmp/x"}}},
+		{"comando desconocido", serveRequest{Command: "rm"}},
+	}
+	for _, tt := range tests {
+		if _, err := s.create(&tt.req); err == nil {
+			t.Errorf("%s: se aceptó la solicitud", tt.name)
+		}
+	}
+	if s.next != 0 {
+		t.Errorf("las solicitudes rechazadas consumieron ids: next = %d", s.next)
+	}
+
+	j, err := s.create(&serveRequest{Command: "scan sni", Flags: map[string]interface{}{"timeout": 2}, Targets: []string{"example.com"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if j.id != "1" {
+		t.Errorf("id = %s, se esperaba 1", j.id)
+	}
+	for _, arg := range j.args {
+		if strings.HasPrefix(arg, "--output=") && !strings.HasPrefix(arg, "--output="+s.dir) {
+			t.Errorf("salida fuera del directorio de serve: %s", arg)
+		}
+	}
+}
+
+func TestServeAuth(t *testing.T) {
+	s := &scanServer{token: "secreto", dir: t.TempDir(), jobs: make(map[string]*serveJob)}
+	h := s.Handler()
+
+	tests := []struct {
+		path   string
+		header string
+		want   int
+	}{
+		{"/api/jobs", "", http.StatusUnauthorized},
+		{"/api/jobs", "Bearer otro", http.StatusUnauthorized},
+		{"/api/jobs", "Bearer secreto", http.StatusOK},
+		{"/api/jobs?token=secreto", "", http.StatusOK},
+		{"/metrics", "", http.StatusUnauthorized},
+		{"/metrics?token=secreto", "", http.StatusOK},
+		{"/", "", http.StatusOK},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+		if tt.header != "" {
+			req.Header.Set("Authorization", tt.header)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if rec.Code != tt.want {
+			t.Errorf("GET %s (%q) = %d, se esperaba %d", tt.path, tt.header, rec.Code, tt.want)
+		}
+	}
+}
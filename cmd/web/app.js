@@ -0,0 +1,268 @@
+"use strict";
+
+// Panel de Alama: lanza escaneos con la API de serve y muestra sus
+// resultados en directo.
+
+const $ = (id) => document.getElementById(id);
+
+let token = "";
+let commands = [];
+let selected = null;
+let events = null;
+let hits = [];
+let sort = { key: "", dir: 1 };
+let renderPending = false;
+
+function initToken() {
+	const m = location.hash.match(/token=([^&]+)/);
+	if (m) {
+		localStorage.setItem("alama-token", decodeURIComponent(m[1]));
+		history.replaceState(null, "", location.pathname);
+	}
+	token = localStorage.getItem("alama-token") || "";
+}
+
+function askToken() {
+	localStorage.removeItem("alama-token");
+	token = "";
+	$("app").hidden = true;
+	$("token-form").hidden = false;
+}
+
+async function api(path, options = {}) {
+	options.headers = Object.assign({ Authorization: "Bearer " + token }, options.headers);
+	const resp = await fetch(path, options);
+	if (resp.status === 401) {
+		askToken();
+		throw new Error("token inválido");
+	}
+	const body = await resp.json();
+	if (!resp.ok) {
+		throw new Error(body.error || resp.statusText);
+	}
+	return body;
+}
+
+function withToken(path) {
+	return path + (path.includes("?") ? "&" : "?") + "token=" + encodeURIComponent(token);
+}
+
+// Formulario de lanzamiento
+
+function renderCommand() {
+	const cmd = commands.find((c) => c.command === $("command").value);
+	if (!cmd) {
+		return;
+	}
+	$("command-help").textContent = cmd.short;
+	$("targets-label").hidden = !cmd.input;
+
+	const box = $("flags");
+	box.replaceChildren();
+	for (const flag of cmd.flags || []) {
+		const label = document.createElement("label");
+		const input = document.createElement("input");
+		input.name = flag.name;
+		input.dataset.type = flag.type;
+		input.dataset.default = flag.default;
+		if (flag.type === "bool") {
+			input.type = "checkbox";
+			input.checked = flag.default === "true";
+			label.append(input, " " + flag.name);
+		} else {
+			input.placeholder = flag.default;
+			label.append(flag.name, input);
+		}
+		label.title = flag.usage;
+		box.append(label);
+	}
+}
+
+function collectFlags() {
+	const flags = {};
+	for (const input of $("flags").querySelectorAll("input")) {
+		if (input.type === "checkbox") {
+			if (String(input.checked) !== input.dataset.default) {
+				flags[input.name] = input.checked;
+			}
+		} else if (input.value.trim() !== "") {
+			flags[input.name] = input.value.trim();
+		}
+	}
+	return flags;
+}
+
+async function launch(ev) {
+	ev.preventDefault();
+	$("job-error").textContent = "";
+	const targets = $("targets-label").hidden ? [] : $("targets").value.split(/\s+/).filter(Boolean);
+	try {
+		const job = await api("/api/jobs", {
+			method: "POST",
+			headers: { "Content-Type": "application/json" },
+			body: JSON.stringify({ command: $("command").value, flags: collectFlags(), targets }),
+		});
+		await refreshJobs();
+		selectJob(job.id);
+	} catch (err) {
+		$("job-error").textContent = err.message;
+	}
+}
+
+// Lista de escaneos
+
+async function refreshJobs() {
+	const jobs = await api("/api/jobs");
+	const list = $("job-list");
+	list.replaceChildren();
+	for (const job of jobs.reverse()) {
+		const li = document.createElement("li");
+		li.classList.toggle("selected", job.id === selected);
+		const name = document.createElement("span");
+		name.textContent = "#" + job.id + " " + job.command;
+		const status = document.createElement("span");
+		status.className = "status-" + job.status;
+		status.textContent = job.status === "running" ? job.progress.percent.toFixed(0) + "%" : job.status;
+		li.append(name, status);
+		li.onclick = () => selectJob(job.id);
+		list.append(li);
+	}
+}
+
+// Escaneo seleccionado
+
+function selectJob(id) {
+	if (events) {
+		events.close();
+	}
+	selected = id;
+	hits = [];
+	scheduleRender();
+
+	$("job").hidden = false;
+	$("job-title").textContent = "Escaneo #" + id;
+	$("job-progress").value = 0;
+	$("job-status").textContent = "";
+	$("export-csv").href = withToken("/api/jobs/" + id + "/hits?format=csv");
+	$("export-json").href = withToken("/api/jobs/" + id + "/hits");
+	$("export-output").href = withToken("/api/jobs/" + id + "/output");
+	$("job-cancel").hidden = false;
+
+	events = new EventSource(withToken("/api/jobs/" + id + "/events"));
+	events.addEventListener("hit", (ev) => {
+		hits.push(JSON.parse(ev.data));
+		scheduleRender();
+	});
+	events.addEventListener("progress", (ev) => showProgress(JSON.parse(ev.data)));
+	events.addEventListener("end", (ev) => {
+		const job = JSON.parse(ev.data);
+		showProgress(job.progress);
+		$("job-status").textContent = job.status + (job.error ? ": " + job.error : "") + " - " + job.results + " resultados";
+		$("job-cancel").hidden = true;
+		events.close();
+		refreshJobs();
+	});
+	refreshJobs();
+}
+
+function showProgress(p) {
+	$("job-progress").value = p.percent;
+	$("job-status").textContent = p.done + " / " + p.total + " - " + p.found + " encontrados";
+}
+
+async function cancelJob() {
+	try {
+		await api("/api/jobs/" + selected, { method: "DELETE" });
+	} catch (err) {
+		$("job-status").textContent = err.message;
+	}
+}
+
+// Tabla de resultados
+
+function scheduleRender() {
+	if (!renderPending) {
+		renderPending = true;
+		requestAnimationFrame(renderHits);
+	}
+}
+
+function compareHits(a, b) {
+	const x = a[sort.key] ?? "";
+	const y = b[sort.key] ?? "";
+	if (typeof x === "number" || typeof y === "number") {
+		return ((x || 0) - (y || 0)) * sort.dir;
+	}
+	return String(x).localeCompare(String(y), undefined, { numeric: true }) * sort.dir;
+}
+
+function renderHits() {
+	renderPending = false;
+	const rows = sort.key ? [...hits].sort(compareHits) : hits;
+	const body = document.querySelector("#hits tbody");
+	body.replaceChildren(...rows.map((hit) => {
+		const tr = document.createElement("tr");
+		const cells = [hit.target, hit.status || "", hit.latency_ms ? hit.latency_ms + " ms" : "", hit.provider || "", hit.line];
+		cells.forEach((value, i) => {
+			const td = document.createElement("td");
+			td.textContent = value;
+			if (i === 4) {
+				td.className = "line";
+			}
+			tr.append(td);
+		});
+		return tr;
+	}));
+	for (const th of document.querySelectorAll("#hits th")) {
+		th.classList.toggle("asc", th.dataset.key === sort.key && sort.dir === 1);
+		th.classList.toggle("desc", th.dataset.key === sort.key && sort.dir === -1);
+	}
+}
+
+function sortBy(key) {
+	sort = { key, dir: sort.key === key ? -sort.dir : 1 };
+	scheduleRender();
+}
+
+// Arranque
+
+async function start() {
+	try {
+		commands = await api("/api/commands");
+	} catch (err) {
+		return;
+	}
+	$("token-form").hidden = true;
+	$("app").hidden = false;
+
+	const select = $("command");
+	select.replaceChildren(...commands.map((c) => new Option(c.command, c.command)));
+	select.value = commands.some((c) => c.command === "httping") ? "httping" : commands[0].command;
+	renderCommand();
+	refreshJobs();
+}
+
+$("token-form").onsubmit = (ev) => {
+	ev.preventDefault();
+	token = $("token").value.trim();
+	localStorage.setItem("alama-token", token);
+	start();
+};
+$("command").onchange = renderCommand;
+$("job-form").onsubmit = launch;
+$("job-cancel").onclick = cancelJob;
+for (const th of document.querySelectorAll("#hits th")) {
+	th.onclick = () => sortBy(th.dataset.key);
+}
+setInterval(() => {
+	if (token && !$("app").hidden) {
+		refreshJobs().catch(() => {});
+	}
+}, 2000);
+
+initToken();
+if (token) {
+	start();
+} else {
+	askToken();
+}
@@ -0,0 +1,333 @@
+// Package banner identifica el servicio que escucha en un puerto TCP a partir
+// de los primeros bytes que envía, y proporciona los mensajes (nudges) que
+// hacen hablar a los servicios que esperan al cliente.
+package banner
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Servicios reconocidos.
+const (
+	SSH     = "ssh"
+	SMTP    = "smtp"
+	FTP     = "ftp"
+	Redis   = "redis"
+	MySQL   = "mysql"
+	RDP     = "rdp"
+	HTTP    = "http"
+	TLS     = "tls"
+	Unknown = "unknown"
+)
+
+// signature reconoce un servicio. match devuelve la versión (puede estar
+// vacía) y si los datos pertenecen al servicio.
+type signature struct {
+	service string
+	match   func(data []byte) (string, bool)
+}
+
+// signatures se evalúan en orden: las binarias y las más concretas van antes
+// que las de texto genéricas (SMTP antes que FTP, ambos empiezan por 220).
+var signatures = []signature{
+	{SSH, matchSSH},
+	{MySQL, matchMySQL},
+	{RDP, matchRDP},
+	{TLS, matchTLS},
+	{Redis, matchRedis},
+	{HTTP, matchHTTP},
+	{SMTP, matchSMTP},
+	{FTP, matchFTP},
+}
+
+// Match identifica el servicio a partir de su banner. Si ninguna firma
+// coincide devuelve Unknown.
+func Match(data []byte) (service, version string) {
+	for _, sig := range signatures {
+		if version, ok := sig.match(data); ok {
+			return sig.service, version
+		}
+	}
+	return Unknown, ""
+}
+
+var (
+	reSSH        = regexp.MustCompile(`^SSH-[\d.]+-(\S+)`)
+	reHTTP       = regexp.MustCompile(`^HTTP/\d(?:\.\d)? \d{3}`)
+	reServer     = regexp.MustCompile(`(?im)^Server:[ \t]*([^\r\n]+)`)
+	reHTTPOnTLS  = regexp.MustCompile(`(?i)client sent an HTTP request to an HTTPS server|plain HTTP request was sent to HTTPS port`)
+	reSMTP       = regexp.MustCompile(`(?i)^220[ -]\S*.*?\bE?SMTP\b[ \t]*([^\r\n]*)`)
+	reFTP        = regexp.MustCompile(`^220[ -]([^\r\n]*)`)
+	reRedis      = regexp.MustCompile(`^(?:\$\d+\r\n#\s*Server|-NOAUTH|-DENIED Redis|-ERR .*[Rr]edis)`)
+	reRedisVer   = regexp.MustCompile(`redis_version:([^\r\n]+)`)
+	reMySQLError = regexp.MustCompile(`(?i)MySQL|MariaDB`)
+)
+
+func matchSSH(data []byte) (string, bool) {
+	m := reSSH.FindSubmatch(data)
+	if m == nil {
+		return "", false
+	}
+	return string(m[1]), true
+}
+
+// matchMySQL reconoce el saludo del protocolo 10 o el paquete de error que
+// envía el servidor cuando rechaza al cliente.
+func matchMySQL(data []byte) (string, bool) {
+	if len(data) < 6 || data[3] != 0 {
+		return "", false
+	}
+	if data[0] == 0 && data[1] == 0 && data[2] == 0 {
+		return "", false
+	}
+
+	switch data[4] {
+	case 0x0a:
+		end := bytes.IndexByte(data[5:], 0)
+		if end <= 0 {
+			return "", false
+		}
+		return string(data[5 : 5+end]), true
+	case 0xff:
+		if reMySQLError.Match(data) || bytes.Contains(data, []byte("is not allowed to connect")) {
+			return "", true
+		}
+	}
+	return "", false
+}
+
+// Protocolos que puede seleccionar un servidor RDP en RDP_NEG_RSP.
+var rdpProtocols = map[uint32]string{
+	0: "rdp",
+	1: "tls",
+	2: "credssp",
+	8: "credssp-early",
+}
+
+// matchRDP reconoce la confirmación de conexión X.224 (TPKT). La versión es
+// el protocolo de seguridad elegido por el servidor.
+func matchRDP(data []byte) (string, bool) {
+	if len(data) < 11 || data[0] != 0x03 || data[1] != 0x00 || data[5] != 0xd0 {
+		return "", false
+	}
+	// RDP_NEG_RSP: tipo 0x02, flags, longitud (2) y protocolo (4).
+	if len(data) >= 19 && data[11] == 0x02 {
+		proto := binary.LittleEndian.Uint32(data[15:19])
+		if name, ok := rdpProtocols[proto]; ok {
+			return name, true
+		}
+		return fmt.Sprintf("protocolo %d", proto), true
+	}
+	return "", true
+}
+
+// tlsVersions traduce los números de versión de TLS.
+var tlsVersions = map[uint16]string{
+	tls.VersionSSL30: "SSL 3.0",
+	tls.VersionTLS10: "TLS 1.0",
+	tls.VersionTLS11: "TLS 1.1",
+	tls.VersionTLS12: "TLS 1.2",
+	tls.VersionTLS13: "TLS 1.3",
+}
+
+func tlsVersion(v uint16) string {
+	if name, ok := tlsVersions[v]; ok {
+		return name
+	}
+	return fmt.Sprintf("0x%04x", v)
+}
+
+// matchTLS reconoce un registro TLS (ServerHello o alerta) o la respuesta
+// que dan los servidores HTTPS a una solicitud en claro.
+func matchTLS(data []byte) (string, bool) {
+	if len(data) >= 5 && data[1] == 0x03 && data[2] <= 0x04 {
+		switch data[0] {
+		case 0x16:
+			return serverHelloVersion(data), true
+		case 0x15:
+			return tlsVersion(binary.BigEndian.Uint16(data[1:3])), true
+		}
+	}
+	if reHTTPOnTLS.Match(data) {
+		return "", true
+	}
+	return "", false
+}
+
+// serverHelloVersion devuelve la versión elegida en el ServerHello, teniendo
+// en cuenta la extensión supported_versions de TLS 1.3.
+func serverHelloVersion(data []byte) string {
+	record := binary.BigEndian.Uint16(data[1:3])
+	hello := data[5:]
+	// Tipo (1), longitud (3), versión (2), random (32) y session id.
+	if len(hello) < 39 || hello[0] != 0x02 {
+		return tlsVersion(record)
+	}
+	version := binary.BigEndian.Uint16(hello[4:6])
+	p := 38
+	p += 1 + int(hello[p])
+	p += 2 + 1 // cipher suite y compresión
+	if len(hello) < p+2 {
+		return tlsVersion(version)
+	}
+	exts := hello[p+2:]
+	if n := int(binary.BigEndian.Uint16(hello[p:])); n < len(exts) {
+		exts = exts[:n]
+	}
+	for len(exts) >= 4 {
+		typ := binary.BigEndian.Uint16(exts[0:2])
+		n := int(binary.BigEndian.Uint16(exts[2:4]))
+		if len(exts) < 4+n {
+			break
+		}
+		if typ == 0x002b && n == 2 {
+			version = binary.BigEndian.Uint16(exts[4:6])
+		}
+		exts = exts[4+n:]
+	}
+	return tlsVersion(version)
+}
+
+func matchRedis(data []byte) (string, bool) {
+	if !reRedis.Match(data) {
+		return "", false
+	}
+	if m := reRedisVer.FindSubmatch(data); m != nil {
+		return strings.TrimSpace(string(m[1])), true
+	}
+	return "", true
+}
+
+func matchHTTP(data []byte) (string, bool) {
+	if !reHTTP.Match(data) {
+		return "", false
+	}
+	head := data
+	if end := bytes.Index(data, []byte("\r\n\r\n")); end >= 0 {
+		head = data[:end]
+	}
+	if m := reServer.FindSubmatch(head); m != nil {
+		return strings.TrimSpace(string(m[1])), true
+	}
+	return "", true
+}
+
+func matchSMTP(data []byte) (string, bool) {
+	m := reSMTP.FindSubmatch(data)
+	if m == nil {
+		return "", false
+	}
+	return strings.TrimSpace(string(m[1])), true
+}
+
+func matchFTP(data []byte) (string, bool) {
+	m := reFTP.FindSubmatch(data)
+	if m == nil {
+		return "", false
+	}
+	version := strings.TrimSpace(string(m[1]))
+	version = strings.TrimSuffix(strings.TrimPrefix(version, "("), ")")
+	return version, true
+}
+
+// NudgeAuto elige el nudge según el puerto; NudgeNone no envía nada.
+const (
+	NudgeAuto = "auto"
+	NudgeNone = "none"
+)
+
+// nudges son los mensajes que hacen responder a los servicios que no hablan
+// primero.
+var nudges = map[string]func() []byte{
+	HTTP:  func() []byte { return []byte("HEAD / HTTP/1.0\r\n\r\n") },
+	Redis: func() []byte { return []byte("INFO server\r\n") },
+	// X.224 Connection Request con RDP_NEG_REQ (TLS y CredSSP).
+	RDP: func() []byte {
+		return []byte{
+			0x03, 0x00, 0x00, 0x13, 0x0e, 0xe0, 0x00, 0x00, 0x00, 0x00, 0x00,
+			0x01, 0x00, 0x08, 0x00, 0x03, 0x00, 0x00, 0x00,
+		}
+	},
+	TLS: clientHello,
+}
+
+// portNudges es el nudge de NudgeAuto según el puerto; el resto recibe el de
+// HTTP, que también delata a los servidores TLS.
+var portNudges = map[int]string{
+	443:  TLS,
+	465:  TLS,
+	636:  TLS,
+	853:  TLS,
+	993:  TLS,
+	995:  TLS,
+	8443: TLS,
+	3389: RDP,
+	6379: Redis,
+}
+
+// ValidNudge indica si name es un nudge válido para NudgeFor.
+func ValidNudge(name string) bool {
+	_, ok := nudges[name]
+	return ok || name == NudgeAuto || name == NudgeNone
+}
+
+// Nudges devuelve los nombres de los nudges disponibles.
+func Nudges() []string {
+	return []string{NudgeAuto, NudgeNone, HTTP, TLS, Redis, RDP}
+}
+
+// NudgeFor devuelve el mensaje del nudge name para el puerto indicado, o nil
+// con NudgeNone.
+func NudgeFor(name string, port int) []byte {
+	if name == NudgeAuto {
+		name = HTTP
+		if n, ok := portNudges[port]; ok {
+			name = n
+		}
+	}
+	if nudge, ok := nudges[name]; ok {
+		return nudge()
+	}
+	return nil
+}
+
+var (
+	helloOnce  sync.Once
+	helloBytes []byte
+)
+
+// clientHello devuelve un ClientHello generado por crypto/tls.
+func clientHello() []byte {
+	helloOnce.Do(func() {
+		conn := &captureConn{}
+		tls.Client(conn, &tls.Config{InsecureSkipVerify: true, ServerName: "localhost"}).Handshake()
+		helloBytes = conn.buf.Bytes()
+	})
+	return helloBytes
+}
+
+// captureConn guarda lo escrito y falla al leer, lo que detiene el handshake
+// tras el ClientHello.
+type captureConn struct {
+	buf bytes.Buffer
+}
+
+var errCaptured = errors.New("banner: ClientHello capturado")
+
+func (c *captureConn) Read([]byte) (int, error)         { return 0, errCaptured }
+func (c *captureConn) Write(b []byte) (int, error)      { return c.buf.Write(b) }
+func (c *captureConn) Close() error                     { return nil }
+func (c *captureConn) LocalAddr() net.Addr              { return &net.TCPAddr{} }
+func (c *captureConn) RemoteAddr() net.Addr             { return &net.TCPAddr{} }
+func (c *captureConn) SetDeadline(time.Time) error      { return nil }
+func (c *captureConn) SetReadDeadline(time.Time) error  { return nil }
+func (c *captureConn) SetWriteDeadline(time.Time) error { return nil }
@@ -0,0 +1,133 @@
+// Package cdn identifica el proveedor de CDN que atiende una respuesta a partir
+// de sus cabeceras, el emisor del certificado y la IP.
+package cdn
+
+import (
+	"net"
+	"net/http"
+	"strings"
+)
+
+// Proveedores reconocidos.
+const (
+	Cloudflare = "cloudflare"
+	Fastly     = "fastly"
+	Akamai     = "akamai"
+	CloudFront = "cloudfront"
+	Google     = "google"
+	Azure      = "azure"
+	Bunny      = "bunny"
+	Incapsula  = "incapsula"
+	Sucuri     = "sucuri"
+	Vercel     = "vercel"
+	Netlify    = "netlify"
+	CDN77      = "cdn77"
+	Unknown    = "unknown"
+)
+
+// Evidence reúne los datos de una respuesta usados para clasificarla.
+type Evidence struct {
+	Header     http.Header
+	CertIssuer string
+	IP         net.IP
+}
+
+// headerRule identifica un proveedor por una cabecera. Si contains está vacío
+// basta con que la cabecera exista; si no, su valor debe contenerlo (sin
+// distinguir mayúsculas).
+type headerRule struct {
+	header   string
+	contains string
+	provider string
+}
+
+// headerRules se evalúan en orden; las cabeceras propias de cada proveedor van
+// antes que las genéricas como Via o X-Cache.
+var headerRules = []headerRule{
+	{"CF-RAY", "", Cloudflare},
+	{"CF-Cache-Status", "", Cloudflare},
+	{"Server", "cloudflare", Cloudflare},
+	{"X-Amz-Cf-Id", "", CloudFront},
+	{"X-Amz-Cf-Pop", "", CloudFront},
+	{"X-Fastly-Request-ID", "", Fastly},
+	{"Fastly-Debug-Digest", "", Fastly},
+	{"X-Served-By", "cache-", Fastly},
+	{"X-Akamai-Transformed", "", Akamai},
+	{"Akamai-GRN", "", Akamai},
+	{"Server", "akamai", Akamai},
+	{"X-Azure-Ref", "", Azure},
+	{"X-MSEdge-Ref", "", Azure},
+	{"X-Iinfo", "", Incapsula},
+	{"X-CDN", "incapsula", Incapsula},
+	{"X-Sucuri-ID", "", Sucuri},
+	{"CDN-PullZone", "", Bunny},
+	{"Server", "bunnycdn", Bunny},
+	{"X-Vercel-Id", "", Vercel},
+	{"X-NF-Request-ID", "", Netlify},
+	{"Server", "cdn77", CDN77},
+	{"Server", "gws", Google},
+	{"Server", "google frontend", Google},
+	{"Via", "cloudfront", CloudFront},
+	{"X-Cache", "cloudfront", CloudFront},
+	{"Via", "google", Google},
+	{"Via", "varnish", Fastly},
+}
+
+// issuerRules asocian fragmentos del emisor del certificado a un proveedor.
+var issuerRules = []struct {
+	contains string
+	provider string
+}{
+	{"cloudflare", Cloudflare},
+	{"microsoft azure", Azure},
+	{"amazon", CloudFront},
+	{"google trust services", Google},
+}
+
+// Classify devuelve el proveedor más probable, o Unknown. Las cabeceras tienen
+// prioridad sobre los rangos de IP y estos sobre el emisor del certificado.
+func Classify(e Evidence) string {
+	if p := classifyHeader(e.Header); p != "" {
+		return p
+	}
+	if e.IP != nil {
+		if p := LookupIP(e.IP); p != "" {
+			return p
+		}
+	}
+	if p := classifyIssuer(e.CertIssuer); p != "" {
+		return p
+	}
+	return Unknown
+}
+
+func classifyHeader(h http.Header) string {
+	for _, rule := range headerRules {
+		values := h.Values(rule.header)
+		if len(values) == 0 {
+			continue
+		}
+		if rule.contains == "" {
+			return rule.provider
+		}
+		for _, v := range values {
+			if strings.Contains(strings.ToLower(v), rule.contains) {
+				return rule.provider
+			}
+		}
+	}
+	return ""
+}
+
+func classifyIssuer(issuer string) string {
+	issuer = strings.ToLower(issuer)
+	if issuer == "" {
+		return ""
+	}
+	for _, rule := range issuerRules {
+		if strings.Contains(issuer, rule.contains) {
+			return rule.provider
+		}
+	}
+	return ""
+}
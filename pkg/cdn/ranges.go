@@ -0,0 +1,236 @@
+package cdn
+
+import (
+	"bufio"
+	"bytes"
+	"embed"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// embeddedRanges contiene los prefijos publicados por cada proveedor, un
+// archivo por proveedor con un CIDR por línea.
+//
+//go:embed ranges/*.txt
+var embeddedRanges embed.FS
+
+// RangeSet son los prefijos de un proveedor y de dónde se cargaron.
+type RangeSet struct {
+	Provider string
+	Source   string // "embebido" o la ruta del archivo local
+	Nets     []*net.IPNet
+}
+
+// V4 devuelve solo los prefijos IPv4.
+func (s *RangeSet) V4() []*net.IPNet {
+	var nets []*net.IPNet
+	for _, ipnet := range s.Nets {
+		if ipnet.IP.To4() != nil {
+			nets = append(nets, ipnet)
+		}
+	}
+	return nets
+}
+
+// V6 devuelve solo los prefijos IPv6.
+func (s *RangeSet) V6() []*net.IPNet {
+	var nets []*net.IPNet
+	for _, ipnet := range s.Nets {
+		if ipnet.IP.To4() == nil {
+			nets = append(nets, ipnet)
+		}
+	}
+	return nets
+}
+
+var (
+	rangesDir  string
+	rangesOnce sync.Once
+	rangesMap  map[string]*RangeSet
+	rangesErr  error
+)
+
+// SetRangesDir indica el directorio con los archivos locales que sustituyen a
+// los embebidos. Debe llamarse antes de la primera consulta.
+func SetRangesDir(dir string) {
+	rangesDir = dir
+}
+
+// RangesDir devuelve el directorio de archivos locales.
+func RangesDir() string {
+	return rangesDir
+}
+
+// providerName valida los nombres de proveedor usados como nombre de archivo.
+var providerName = regexp.MustCompile(`^[a-z0-9][a-z0-9-]*$`)
+
+// loadRanges carga los rangos embebidos y los sustituye por los archivos
+// locales del mismo proveedor, si existen.
+func loadRanges() (map[string]*RangeSet, error) {
+	rangesOnce.Do(func() {
+		rangesMap = make(map[string]*RangeSet)
+
+		entries, err := embeddedRanges.ReadDir("ranges")
+		if err != nil {
+			rangesErr = err
+			return
+		}
+		for _, entry := range entries {
+			data, err := embeddedRanges.ReadFile("ranges/" + entry.Name())
+			if err != nil {
+				rangesErr = err
+				return
+			}
+			provider := strings.TrimSuffix(entry.Name(), ".txt")
+			nets, err := ParseRanges(bytes.NewReader(data))
+			if err != nil {
+				rangesErr = fmt.Errorf("%s: %w", entry.Name(), err)
+				return
+			}
+			rangesMap[provider] = &RangeSet{Provider: provider, Source: "embebido", Nets: nets}
+		}
+
+		if rangesDir == "" {
+			return
+		}
+		files, _ := filepath.Glob(filepath.Join(rangesDir, "*.txt"))
+		for _, path := range files {
+			provider := strings.TrimSuffix(filepath.Base(path), ".txt")
+			if !providerName.MatchString(provider) {
+				continue
+			}
+			f, err := os.Open(path)
+			if err != nil {
+				rangesErr = err
+				return
+			}
+			nets, err := ParseRanges(f)
+			f.Close()
+			if err != nil {
+				rangesErr = fmt.Errorf("%s: %w", path, err)
+				return
+			}
+			rangesMap[provider] = &RangeSet{Provider: provider, Source: path, Nets: nets}
+		}
+	})
+
+	return rangesMap, rangesErr
+}
+
+// ParseRanges lee un CIDR por línea. Las líneas vacías y las que empiezan
+// por # se ignoran.
+func ParseRanges(r io.Reader) ([]*net.IPNet, error) {
+	var nets []*net.IPNet
+
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		_, ipnet, err := net.ParseCIDR(line)
+		if err != nil {
+			return nil, fmt.Errorf("línea %d: %w", n, err)
+		}
+		nets = append(nets, ipnet)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return nets, nil
+}
+
+// Providers devuelve los proveedores con rangos conocidos, ordenados.
+func Providers() ([]string, error) {
+	m, err := loadRanges()
+	if err != nil {
+		return nil, err
+	}
+
+	providers := make([]string, 0, len(m))
+	for provider := range m {
+		providers = append(providers, provider)
+	}
+	sort.Strings(providers)
+	return providers, nil
+}
+
+// Ranges devuelve los rangos del proveedor.
+func Ranges(provider string) (*RangeSet, error) {
+	m, err := loadRanges()
+	if err != nil {
+		return nil, err
+	}
+
+	set, ok := m[strings.ToLower(provider)]
+	if !ok {
+		return nil, fmt.Errorf("proveedor sin rangos conocidos: %s", provider)
+	}
+	return set, nil
+}
+
+// LookupIP devuelve el proveedor cuyo rango contiene la IP, o "". Si la IP
+// está en rangos de varios proveedores gana el prefijo más largo y, a igual
+// prefijo, el primer proveedor por orden alfabético.
+func LookupIP(ip net.IP) string {
+	m, err := loadRanges()
+	if err != nil {
+		return ""
+	}
+
+	best, bestLen := "", -1
+	for provider, set := range m {
+		for _, ipnet := range set.Nets {
+			if !ipnet.Contains(ip) {
+				continue
+			}
+			ones, _ := ipnet.Mask.Size()
+			if ones > bestLen || ones == bestLen && provider < best {
+				best, bestLen = provider, ones
+			}
+		}
+	}
+	return best
+}
+
+// UpdateRanges valida los rangos leídos de r y los guarda en el directorio
+// local como sustitutos de los embebidos. Devuelve la ruta escrita.
+func UpdateRanges(provider string, r io.Reader) (string, int, error) {
+	provider = strings.ToLower(provider)
+	if !providerName.MatchString(provider) {
+		return "", 0, fmt.Errorf("nombre de proveedor inválido: %s", provider)
+	}
+	if rangesDir == "" {
+		return "", 0, fmt.Errorf("no hay directorio local de rangos")
+	}
+
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return "", 0, err
+	}
+	nets, err := ParseRanges(bytes.NewReader(data))
+	if err != nil {
+		return "", 0, err
+	}
+	if len(nets) == 0 {
+		return "", 0, fmt.Errorf("el archivo no contiene rangos")
+	}
+
+	if err := os.MkdirAll(rangesDir, 0755); err != nil {
+		return "", 0, err
+	}
+	path := filepath.Join(rangesDir, provider+".txt")
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return "", 0, err
+	}
+
+	return path, len(nets), nil
+}
@@ -0,0 +1,53 @@
+package cdn
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestMain carga unos rangos locales que se solapan entre sí antes de la
+// primera consulta.
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "alama-ranges")
+	if err != nil {
+		panic(err)
+	}
+	files := map[string]string{
+		"prueba-ancho.txt":    "10.0.0.0/8\n",
+		"prueba-estrecho.txt": "10.1.0.0/16\n",
+		"prueba-b.txt":        "192.0.2.0/24\n",
+		"prueba-a.txt":        "# mismo prefijo que prueba-b\n192.0.2.0/24\n",
+	}
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0644); err != nil {
+			panic(err)
+		}
+	}
+	SetRangesDir(dir)
+
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+func TestLookupIP(t *testing.T) {
+	tests := []struct {
+		ip   string
+		want string
+	}{
+		{"10.2.3.4", "prueba-ancho"},
+		{"10.1.2.3", "prueba-estrecho"}, // el prefijo más largo gana
+		{"192.0.2.10", "prueba-a"},      // a igual prefijo, orden alfabético
+		{"198.51.100.1", ""},
+	}
+	for _, tt := range tests {
+		// El mapa se recorre en otro orden en cada llamada.
+		for i := 0; i < 20; i++ {
+			if got := LookupIP(net.ParseIP(tt.ip)); got != tt.want {
+				t.Fatalf("LookupIP(%s) = %q, se esperaba %q", tt.ip, got, tt.want)
+			}
+		}
+	}
+}
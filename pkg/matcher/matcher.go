@@ -0,0 +1,441 @@
+// Package matcher implementa un pequeño lenguaje para filtrar respuestas HTTP
+// por código de estado, cabeceras, cuerpo y tamaño.
+//
+// Términos:
+//
+//	status:200,2xx,300-399   código de estado (lista, clases o rangos)
+//	header:Server            la cabecera existe
+//	header:Server=cloudflare la cabecera es igual al valor (sin distinguir mayúsculas)
+//	header:Server~cloud.*    la cabecera coincide con la expresión regular
+//	body:texto               el cuerpo contiene el texto
+//	body~regex               el cuerpo coincide con la expresión regular
+//	length:100-5000          tamaño del contenido (también 100- o -5000)
+//
+// Los términos se combinan con and (&&), or (||), not (!) y paréntesis. Dos
+// términos seguidos sin operador se combinan con and. Los valores con
+// espacios o paréntesis pueden ir entre comillas: body:"hola mundo".
+package matcher
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Response es la parte de una respuesta HTTP que se puede evaluar.
+type Response struct {
+	StatusCode    int
+	Header        http.Header
+	Body          []byte
+	ContentLength int64
+}
+
+// Matcher evalúa una respuesta.
+type Matcher interface {
+	Match(r *Response) bool
+}
+
+type andMatcher []Matcher
+
+func (m andMatcher) Match(r *Response) bool {
+	for _, sub := range m {
+		if !sub.Match(r) {
+			return false
+		}
+	}
+	return true
+}
+
+type orMatcher []Matcher
+
+func (m orMatcher) Match(r *Response) bool {
+	for _, sub := range m {
+		if sub.Match(r) {
+			return true
+		}
+	}
+	return false
+}
+
+type notMatcher struct {
+	m Matcher
+}
+
+func (m notMatcher) Match(r *Response) bool {
+	return !m.m.Match(r)
+}
+
+// intRange es un rango cerrado; max < 0 indica sin límite superior.
+type intRange struct {
+	min, max int64
+}
+
+func (rg intRange) contains(n int64) bool {
+	return n >= rg.min && (rg.max < 0 || n <= rg.max)
+}
+
+type statusMatcher []intRange
+
+func (m statusMatcher) Match(r *Response) bool {
+	for _, rg := range m {
+		if rg.contains(int64(r.StatusCode)) {
+			return true
+		}
+	}
+	return false
+}
+
+type headerMatcher struct {
+	name  string
+	value string
+	re    *regexp.Regexp
+}
+
+func (m headerMatcher) Match(r *Response) bool {
+	values := r.Header.Values(m.name)
+	if len(values) == 0 {
+		return false
+	}
+	if m.re == nil && m.value == "" {
+		return true
+	}
+	for _, v := range values {
+		if m.re != nil && m.re.MatchString(v) {
+			return true
+		}
+		if m.re == nil && strings.EqualFold(v, m.value) {
+			return true
+		}
+	}
+	return false
+}
+
+type bodyMatcher struct {
+	text []byte
+	re   *regexp.Regexp
+}
+
+func (m bodyMatcher) Match(r *Response) bool {
+	if m.re != nil {
+		return m.re.Match(r.Body)
+	}
+	return bytes.Contains(r.Body, m.text)
+}
+
+type lengthMatcher intRange
+
+func (m lengthMatcher) Match(r *Response) bool {
+	n := r.ContentLength
+	if n < 0 {
+		n = int64(len(r.Body))
+	}
+	return intRange(m).contains(n)
+}
+
+// All devuelve un matcher que se cumple si se cumplen todos los indicados.
+func All(m ...Matcher) Matcher {
+	return andMatcher(m)
+}
+
+// UsesBody indica si el matcher necesita leer el cuerpo de la respuesta.
+func UsesBody(m Matcher) bool {
+	switch m := m.(type) {
+	case andMatcher:
+		for _, sub := range m {
+			if UsesBody(sub) {
+				return true
+			}
+		}
+	case orMatcher:
+		for _, sub := range m {
+			if UsesBody(sub) {
+				return true
+			}
+		}
+	case notMatcher:
+		return UsesBody(m.m)
+	case bodyMatcher:
+		return true
+	case lengthMatcher:
+		return true
+	}
+	return false
+}
+
+// ParseStatus interpreta una lista de códigos de estado como 200,2xx,300-399.
+func ParseStatus(list string) (Matcher, error) {
+	var m statusMatcher
+
+	for _, item := range strings.Split(list, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+
+		lower := strings.ToLower(item)
+		if len(lower) == 3 && strings.HasSuffix(lower, "xx") {
+			class, err := strconv.Atoi(lower[:1])
+			if err != nil || class < 1 || class > 5 {
+				return nil, fmt.Errorf("clase de estado inválida: %s", item)
+			}
+			m = append(m, intRange{int64(class * 100), int64(class*100 + 99)})
+			continue
+		}
+
+		rg, err := parseRange(item)
+		if err != nil {
+			return nil, fmt.Errorf("código de estado inválido: %s", item)
+		}
+		if rg.max < 0 && strings.Contains(item, "-") {
+			rg.max = 599
+		}
+		m = append(m, rg)
+	}
+
+	if len(m) == 0 {
+		return nil, fmt.Errorf("lista de estados vacía")
+	}
+	return m, nil
+}
+
+// parseRange interpreta n, n-m, n- y -m.
+func parseRange(s string) (intRange, error) {
+	lo, hi, found := strings.Cut(s, "-")
+	if !found {
+		n, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return intRange{}, err
+		}
+		return intRange{n, n}, nil
+	}
+
+	rg := intRange{0, -1}
+	var err error
+	if lo != "" {
+		if rg.min, err = strconv.ParseInt(lo, 10, 64); err != nil {
+			return intRange{}, err
+		}
+	}
+	if hi != "" {
+		if rg.max, err = strconv.ParseInt(hi, 10, 64); err != nil {
+			return intRange{}, err
+		}
+	}
+	if lo == "" && hi == "" {
+		return intRange{}, fmt.Errorf("rango vacío")
+	}
+	return rg, nil
+}
+
+// parseTerm interpreta un término individual.
+func parseTerm(term string) (Matcher, error) {
+	switch {
+	case strings.HasPrefix(term, "status:"):
+		return ParseStatus(term[len("status:"):])
+
+	case strings.HasPrefix(term, "header:"):
+		spec := term[len("header:"):]
+		if i := strings.IndexAny(spec, "~="); i >= 0 {
+			m := headerMatcher{name: spec[:i]}
+			if spec[i] == '~' {
+				re, err := regexp.Compile("(?i)" + spec[i+1:])
+				if err != nil {
+					return nil, err
+				}
+				m.re = re
+			} else {
+				m.value = spec[i+1:]
+			}
+			if m.name == "" {
+				return nil, fmt.Errorf("cabecera vacía: %s", term)
+			}
+			return m, nil
+		}
+		if spec == "" {
+			return nil, fmt.Errorf("cabecera vacía: %s", term)
+		}
+		return headerMatcher{name: spec}, nil
+
+	case strings.HasPrefix(term, "body:"):
+		return bodyMatcher{text: []byte(term[len("body:"):])}, nil
+
+	case strings.HasPrefix(term, "body~"):
+		re, err := regexp.Compile(term[len("body~"):])
+		if err != nil {
+			return nil, err
+		}
+		return bodyMatcher{re: re}, nil
+
+	case strings.HasPrefix(term, "length:"):
+		rg, err := parseRange(term[len("length:"):])
+		if err != nil {
+			return nil, fmt.Errorf("tamaño inválido: %s", term)
+		}
+		return lengthMatcher(rg), nil
+	}
+
+	return nil, fmt.Errorf("término desconocido: %s", term)
+}
+
+// Parse compila una expresión completa.
+func Parse(expr string) (Matcher, error) {
+	tokens, err := tokenize(expr)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 {
+		return nil, fmt.Errorf("expresión vacía")
+	}
+
+	p := &parser{tokens: tokens}
+	m, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.tokens) {
+		return nil, fmt.Errorf("símbolo inesperado: %s", p.tokens[p.pos])
+	}
+	return m, nil
+}
+
+// tokenize separa la expresión en términos, operadores y paréntesis.
+func tokenize(expr string) ([]string, error) {
+	var tokens []string
+	var cur strings.Builder
+	inQuote := false
+
+	flush := func() {
+		if cur.Len() > 0 {
+			tokens = append(tokens, cur.String())
+			cur.Reset()
+		}
+	}
+
+	for i := 0; i < len(expr); i++ {
+		ch := expr[i]
+		switch {
+		case ch == '"':
+			inQuote = !inQuote
+		case inQuote:
+			cur.WriteByte(ch)
+		case ch == ' ' || ch == '\t':
+			flush()
+		case ch == '(' || ch == ')':
+			flush()
+			tokens = append(tokens, string(ch))
+		case ch == '!' && cur.Len() == 0:
+			tokens = append(tokens, "!")
+		case (ch == '&' || ch == '|') && i+1 < len(expr) && expr[i+1] == ch:
+			flush()
+			tokens = append(tokens, expr[i:i+2])
+			i++
+		default:
+			cur.WriteByte(ch)
+		}
+	}
+	if inQuote {
+		return nil, fmt.Errorf("comillas sin cerrar")
+	}
+	flush()
+
+	return tokens, nil
+}
+
+type parser struct {
+	tokens []string
+	pos    int
+}
+
+func (p *parser) peek() string {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos]
+	}
+	return ""
+}
+
+func (p *parser) parseOr() (Matcher, error) {
+	m, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+
+	or := orMatcher{m}
+	for {
+		tok := strings.ToLower(p.peek())
+		if tok != "or" && tok != "||" {
+			break
+		}
+		p.pos++
+		m, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		or = append(or, m)
+	}
+
+	if len(or) == 1 {
+		return or[0], nil
+	}
+	return or, nil
+}
+
+func (p *parser) parseAnd() (Matcher, error) {
+	m, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+
+	and := andMatcher{m}
+	for {
+		tok := strings.ToLower(p.peek())
+		if tok == "and" || tok == "&&" {
+			p.pos++
+		} else if tok == "" || tok == ")" || tok == "or" || tok == "||" {
+			break
+		}
+		m, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		and = append(and, m)
+	}
+
+	if len(and) == 1 {
+		return and[0], nil
+	}
+	return and, nil
+}
+
+func (p *parser) parseUnary() (Matcher, error) {
+	tok := p.peek()
+	switch strings.ToLower(tok) {
+	case "":
+		return nil, fmt.Errorf("expresión incompleta")
+	case "not", "!":
+		p.pos++
+		m, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return notMatcher{m}, nil
+	case "(":
+		p.pos++
+		m, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if p.peek() != ")" {
+			return nil, fmt.Errorf("falta ')'")
+		}
+		p.pos++
+		return m, nil
+	case ")", "and", "&&", "or", "||":
+		return nil, fmt.Errorf("símbolo inesperado: %s", tok)
+	}
+
+	p.pos++
+	return parseTerm(tok)
+}
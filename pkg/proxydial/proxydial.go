@@ -0,0 +1,228 @@
+// Package proxydial abre conexiones TCP a través de proxies HTTP CONNECT,
+// SOCKS4/4a y SOCKS5.
+package proxydial
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"golang.org/x/net/proxy"
+)
+
+// Dialer abre conexiones con soporte de contexto.
+type Dialer interface {
+	DialContext(ctx context.Context, network, addr string) (net.Conn, error)
+}
+
+// Direct abre conexiones sin proxy.
+var Direct Dialer = &net.Dialer{}
+
+// noDeadline quita el plazo fijado durante la negociación con el proxy.
+var noDeadline time.Time
+
+// FromURL devuelve el Dialer para la URL del proxy. Esquemas admitidos:
+// http (CONNECT), socks4, socks4a, socks5 (resolución local) y socks5h
+// (resolución en el proxy). forward es el Dialer usado para llegar al proxy;
+// si es nil se usa Direct.
+func FromURL(u *url.URL, forward Dialer) (Dialer, error) {
+	if forward == nil {
+		forward = Direct
+	}
+
+	switch u.Scheme {
+	case "http":
+		return &connectDialer{proxy: u, forward: forward}, nil
+	case "socks4":
+		return &socks4Dialer{addr: u.Host, user: u.User.Username(), forward: forward}, nil
+	case "socks4a":
+		return &socks4Dialer{addr: u.Host, user: u.User.Username(), remoteDNS: true, forward: forward}, nil
+	case "socks5", "socks5h":
+		var auth *proxy.Auth
+		if u.User != nil {
+			password, _ := u.User.Password()
+			auth = &proxy.Auth{User: u.User.Username(), Password: password}
+		}
+		d, err := proxy.SOCKS5("tcp", u.Host, auth, contextDialer{forward})
+		if err != nil {
+			return nil, err
+		}
+		if u.Scheme == "socks5" {
+			return &localResolveDialer{d.(proxy.ContextDialer)}, nil
+		}
+		return d.(proxy.ContextDialer), nil
+	}
+
+	return nil, fmt.Errorf("esquema de proxy no soportado: %s", u.Scheme)
+}
+
+// contextDialer adapta un Dialer a proxy.ContextDialer.
+type contextDialer struct {
+	Dialer
+}
+
+func (d contextDialer) Dial(network, addr string) (net.Conn, error) {
+	return d.DialContext(context.Background(), network, addr)
+}
+
+// localResolveDialer resuelve el nombre antes de entregarlo al proxy.
+type localResolveDialer struct {
+	d proxy.ContextDialer
+}
+
+func (d *localResolveDialer) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	if net.ParseIP(host) == nil {
+		ips, err := net.DefaultResolver.LookupIP(ctx, "ip", host)
+		if err != nil {
+			return nil, err
+		}
+		addr = net.JoinHostPort(ips[0].String(), port)
+	}
+	return d.d.DialContext(ctx, network, addr)
+}
+
+// connectDialer abre túneles con el método CONNECT de un proxy HTTP.
+type connectDialer struct {
+	proxy   *url.URL
+	forward Dialer
+}
+
+func (d *connectDialer) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	conn, err := d.forward.DialContext(ctx, "tcp", d.proxy.Host)
+	if err != nil {
+		return nil, err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+		defer conn.SetDeadline(noDeadline)
+	}
+
+	req := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Opaque: addr},
+		Host:   addr,
+		Header: make(http.Header),
+	}
+	if u := d.proxy.User; u != nil {
+		password, _ := u.Password()
+		creds := base64.StdEncoding.EncodeToString([]byte(u.Username() + ":" + password))
+		req.Header.Set("Proxy-Authorization", "Basic "+creds)
+	}
+	if err := req.Write(conn); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, req)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		conn.Close()
+		return nil, fmt.Errorf("el proxy rechazó CONNECT: %s", resp.Status)
+	}
+
+	if br.Buffered() > 0 {
+		return &bufferedConn{Conn: conn, r: br}, nil
+	}
+	return conn, nil
+}
+
+// bufferedConn conserva los bytes leídos de más tras la respuesta CONNECT.
+type bufferedConn struct {
+	net.Conn
+	r io.Reader
+}
+
+func (c *bufferedConn) Read(b []byte) (int, error) {
+	return c.r.Read(b)
+}
+
+// socks4Dialer implementa SOCKS4 y, con remoteDNS, SOCKS4a.
+type socks4Dialer struct {
+	addr      string
+	user      string
+	remoteDNS bool
+	forward   Dialer
+}
+
+func (d *socks4Dialer) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	host, portStr, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil {
+		return nil, err
+	}
+
+	ip := net.ParseIP(host).To4()
+	if ip == nil && !d.remoteDNS {
+		ips, err := net.DefaultResolver.LookupIP(ctx, "ip4", host)
+		if err != nil {
+			return nil, err
+		}
+		ip = ips[0].To4()
+	}
+
+	req := []byte{4, 1, 0, 0}
+	binary.BigEndian.PutUint16(req[2:], uint16(port))
+	if ip != nil {
+		req = append(req, ip...)
+		req = append(req, d.user...)
+		req = append(req, 0)
+	} else {
+		// SOCKS4a: IP 0.0.0.1 y el nombre tras el usuario.
+		req = append(req, 0, 0, 0, 1)
+		req = append(req, d.user...)
+		req = append(req, 0)
+		req = append(req, host...)
+		req = append(req, 0)
+	}
+
+	conn, err := d.forward.DialContext(ctx, "tcp", d.addr)
+	if err != nil {
+		return nil, err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+		defer conn.SetDeadline(noDeadline)
+	}
+
+	if _, err := conn.Write(req); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	resp := make([]byte, 8)
+	if _, err := io.ReadFull(conn, resp); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if resp[0] != 0 {
+		conn.Close()
+		return nil, errors.New("respuesta SOCKS4 inválida")
+	}
+	if resp[1] != 90 {
+		conn.Close()
+		return nil, fmt.Errorf("el proxy SOCKS4 rechazó la conexión (código %d)", resp[1])
+	}
+
+	return conn, nil
+}
@@ -0,0 +1,323 @@
+package queuescanner
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// Probe es la etiqueta probe de las métricas de los escáneres que se creen a
+// continuación, normalmente el comando que los lanza.
+var Probe = "scan"
+
+// Buckets son los límites, en segundos, del histograma de latencia.
+var Buckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// Clase de fallo de ScanFailed, cuando la sonda responde pero el resultado
+// se descarta.
+const FailRejected = "rejected"
+
+// rateWindow son los segundos con los que se calcula el ritmo actual.
+const rateWindow = 10
+
+type rateSlot struct {
+	sec int64
+	n   int
+}
+
+// metrics son los contadores de un Ctx. Se protegen con Ctx.mx.
+type metrics struct {
+	probe     string
+	start     time.Time
+	total     int
+	started   int
+	successes int
+	inFlight  int
+	failures  map[string]int
+	buckets   []uint64
+	sum       float64
+	rate      [rateWindow]rateSlot
+}
+
+// registry guarda todos los Ctx del proceso para exponer sus métricas.
+var registry struct {
+	mu   sync.Mutex
+	ctxs []*Ctx
+}
+
+func register(c *Ctx) {
+	c.m = metrics{
+		probe:    Probe,
+		start:    time.Now(),
+		failures: make(map[string]int),
+		buckets:  make([]uint64, len(Buckets)),
+	}
+	registry.mu.Lock()
+	registry.ctxs = append(registry.ctxs, c)
+	registry.mu.Unlock()
+}
+
+// NewCtx devuelve un Ctx registrado en las métricas para los comandos que
+// reparten total objetivos con sus propios hilos en lugar de con
+// QueueScanner. Cada escaneo se anota con Track.
+func NewCtx(total int) *Ctx {
+	c := &Ctx{}
+	register(c)
+	c.m.total = total
+	return c
+}
+
+// Track ejecuta el escaneo de un objetivo y lo cuenta en las métricas igual
+// que los de QueueScanner, sin guardarlo en las listas. fn devuelve si hubo
+// resultado y, si no, el error de la sonda (nil si respondió y se descartó).
+func (c *Ctx) Track(fn func() (bool, error)) {
+	c.begin()
+	start := time.Now()
+	ok, err := fn()
+	c.end(time.Since(start))
+
+	c.mx.Lock()
+	if ok {
+		c.m.successes++
+	} else {
+		c.m.failures[ErrorClass(err)]++
+	}
+	c.mx.Unlock()
+}
+
+// begin anota que un hilo empieza a escanear un elemento de la cola.
+func (c *Ctx) begin() {
+	c.mx.Lock()
+	c.m.started++
+	c.m.inFlight++
+	c.mx.Unlock()
+}
+
+// end anota el fin de un escaneo y su duración.
+func (c *Ctx) end(d time.Duration) {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+
+	c.ScanComplete++
+	c.m.inFlight--
+
+	s := d.Seconds()
+	c.m.sum += s
+	for i, le := range Buckets {
+		if s <= le {
+			c.m.buckets[i]++
+		}
+	}
+
+	sec := time.Now().Unix()
+	slot := &c.m.rate[sec%rateWindow]
+	if slot.sec != sec {
+		*slot = rateSlot{sec: sec}
+	}
+	slot.n++
+}
+
+// ScanError es ScanFailed con el error que hizo fallar la sonda, que se
+// cuenta en las métricas por su clase (ver ErrorClass).
+func (c *Ctx) ScanError(a interface{}, err error, fn func()) {
+	c.scanFailed(a, ErrorClass(err), fn)
+}
+
+// ErrorClass agrupa un error de red en una clase corta: timeout, refused,
+// reset, unreachable, nxdomain, dns, tls, eof u other. Sin error devuelve
+// FailRejected.
+func ErrorClass(err error) string {
+	if err == nil {
+		return FailRejected
+	}
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		switch {
+		case dnsErr.IsNotFound:
+			return "nxdomain"
+		case dnsErr.IsTimeout:
+			return "timeout"
+		}
+		return "dns"
+	}
+	var netErr net.Error
+	if errors.Is(err, context.DeadlineExceeded) || errors.As(err, &netErr) && netErr.Timeout() {
+		return "timeout"
+	}
+	switch {
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return "refused"
+	case errors.Is(err, syscall.ECONNRESET), errors.Is(err, syscall.EPIPE):
+		return "reset"
+	case errors.Is(err, syscall.EHOSTUNREACH), errors.Is(err, syscall.ENETUNREACH):
+		return "unreachable"
+	case errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+		return "eof"
+	}
+	var certErr *tls.CertificateVerificationError
+	var alertErr tls.AlertError
+	var recordErr tls.RecordHeaderError
+	if errors.As(err, &certErr) || errors.As(err, &alertErr) || errors.As(err, &recordErr) || strings.HasPrefix(err.Error(), "tls: ") {
+		return "tls"
+	}
+	return "other"
+}
+
+// Stats son las métricas de una sonda. Los escáneres con la misma sonda se
+// suman.
+type Stats struct {
+	Probe     string            `json:"probe"`
+	Labels    map[string]string `json:"labels,omitempty"` // etiquetas extra, p. ej. job
+	Probes    int               `json:"probes"`
+	Successes int               `json:"successes"`
+	Failures  map[string]int    `json:"failures"`
+	InFlight  int               `json:"in_flight"`
+	Queued    int               `json:"queued"`
+	Rate      float64           `json:"rate"`    // escaneos por segundo en los últimos segundos
+	Buckets   []uint64          `json:"buckets"` // acumulados, uno por límite de Buckets
+	Sum       float64           `json:"sum"`
+}
+
+// Snapshot devuelve las métricas actuales de todos los escáneres del
+// proceso, una entrada por sonda.
+func Snapshot() []Stats {
+	registry.mu.Lock()
+	ctxs := append([]*Ctx(nil), registry.ctxs...)
+	registry.mu.Unlock()
+
+	now := time.Now()
+	byProbe := make(map[string]*Stats)
+	for _, c := range ctxs {
+		c.mx.Lock()
+		st := byProbe[c.m.probe]
+		if st == nil {
+			st = &Stats{Probe: c.m.probe, Failures: make(map[string]int), Buckets: make([]uint64, len(Buckets))}
+			byProbe[c.m.probe] = st
+		}
+		st.Probes += c.ScanComplete
+		st.Successes += c.m.successes
+		for class, n := range c.m.failures {
+			st.Failures[class] += n
+		}
+		st.InFlight += c.m.inFlight
+		st.Queued += c.m.total - c.m.started
+		for i, n := range c.m.buckets {
+			st.Buckets[i] += n
+		}
+		st.Sum += c.m.sum
+		st.Rate += c.m.currentRate(now)
+		c.mx.Unlock()
+	}
+
+	stats := make([]Stats, 0, len(byProbe))
+	for _, st := range byProbe {
+		stats = append(stats, *st)
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Probe < stats[j].Probe })
+	return stats
+}
+
+func (m *metrics) currentRate(now time.Time) float64 {
+	sec := now.Unix()
+	n := 0
+	for _, slot := range m.rate {
+		if slot.sec > sec-rateWindow && slot.sec <= sec {
+			n += slot.n
+		}
+	}
+	// Al empezar, la ventana es el tiempo transcurrido.
+	window := now.Sub(m.start).Seconds()
+	if window > rateWindow {
+		window = rateWindow
+	}
+	if window < 1 {
+		window = 1
+	}
+	return float64(n) / window
+}
+
+// WriteMetrics escribe las métricas en el formato de texto de Prometheus.
+func WriteMetrics(w io.Writer, stats []Stats) {
+	simple := func(name, kind, help string, value func(Stats) float64) {
+		writeHeader(w, name, kind, help)
+		for _, st := range stats {
+			fmt.Fprintf(w, "%s{%s} %s\n", name, st.labels(), formatFloat(value(st)))
+		}
+	}
+
+	simple("alama_probes_total", "counter", "Sondas completadas.", func(st Stats) float64 { return float64(st.Probes) })
+	simple("alama_probe_successes_total", "counter", "Sondas con resultado.", func(st Stats) float64 { return float64(st.Successes) })
+
+	writeHeader(w, "alama_probe_failures_total", "counter", "Sondas fallidas por clase de error.")
+	for _, st := range stats {
+		classes := make([]string, 0, len(st.Failures))
+		for class := range st.Failures {
+			classes = append(classes, class)
+		}
+		sort.Strings(classes)
+		for _, class := range classes {
+			fmt.Fprintf(w, "alama_probe_failures_total{%s,class=\"%s\"} %d\n", st.labels(), labelValue(class), st.Failures[class])
+		}
+	}
+
+	simple("alama_workers_in_flight", "gauge", "Hilos escaneando en este momento.", func(st Stats) float64 { return float64(st.InFlight) })
+	simple("alama_queue_depth", "gauge", "Objetivos pendientes en la cola.", func(st Stats) float64 { return float64(st.Queued) })
+	simple("alama_probe_rate", "gauge", "Sondas por segundo en los últimos segundos.", func(st Stats) float64 { return st.Rate })
+
+	writeHeader(w, "alama_probe_duration_seconds", "histogram", "Duración de cada sonda.")
+	for _, st := range stats {
+		labels := st.labels()
+		for i, le := range Buckets {
+			fmt.Fprintf(w, "alama_probe_duration_seconds_bucket{%s,le=\"%s\"} %d\n", labels, formatFloat(le), st.Buckets[i])
+		}
+		fmt.Fprintf(w, "alama_probe_duration_seconds_bucket{%s,le=\"+Inf\"} %d\n", labels, st.Probes)
+		fmt.Fprintf(w, "alama_probe_duration_seconds_sum{%s} %s\n", labels, formatFloat(st.Sum))
+		fmt.Fprintf(w, "alama_probe_duration_seconds_count{%s} %d\n", labels, st.Probes)
+	}
+}
+
+func writeHeader(w io.Writer, name, kind, help string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+}
+
+// labels devuelve las etiquetas de st en orden: probe y después las extra.
+func (st Stats) labels() string {
+	keys := make([]string, 0, len(st.Labels))
+	for k := range st.Labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	labels := []string{`probe="` + labelValue(st.Probe) + `"`}
+	for _, k := range keys {
+		labels = append(labels, k+`="`+labelValue(st.Labels[k])+`"`)
+	}
+	return strings.Join(labels, ",")
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func labelValue(s string) string {
+	return labelEscaper.Replace(s)
+}
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// Handler sirve las métricas del proceso para Prometheus.
+func Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		WriteMetrics(w, Snapshot())
+	})
+}
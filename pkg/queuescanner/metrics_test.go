@@ -0,0 +1,95 @@
+package queuescanner
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"syscall"
+	"testing"
+)
+
+func stats(probe string) Stats {
+	for _, st := range Snapshot() {
+		if st.Probe == probe {
+			return st
+		}
+	}
+	return Stats{}
+}
+
+func TestTrack(t *testing.T) {
+	Probe = "prueba-track"
+	c := NewCtx(4)
+
+	c.Track(func() (bool, error) { return true, nil })
+	c.Track(func() (bool, error) { return false, nil })
+	c.Track(func() (bool, error) { return false, syscall.ECONNREFUSED })
+
+	st := stats("prueba-track")
+	if st.Probes != 3 || st.Successes != 1 || st.Queued != 1 || st.InFlight != 0 {
+		t.Errorf("stats = %+v", st)
+	}
+	if st.Failures[FailRejected] != 1 || st.Failures["refused"] != 1 {
+		t.Errorf("fallos = %v", st.Failures)
+	}
+	if len(c.ScanSuccessList) != 0 || len(c.ScanFailedList) != 0 {
+		t.Errorf("Track no debe guardar los resultados en las listas")
+	}
+}
+
+func TestQueueScannerMetrics(t *testing.T) {
+	Probe = "prueba-cola"
+	qs := NewQueueScanner(4, func(c *Ctx, a *QueueScannerScanParams) {
+		if a.Data.(int)%2 == 0 {
+			c.ScanSuccess(a.Data, nil)
+		} else {
+			c.ScanError(a.Data, errors.New("tls: handshake failure"), nil)
+		}
+	})
+
+	// Add y Snapshot desde otros hilos a la vez.
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			qs.Add(&QueueScannerScanParams{Name: fmt.Sprint(i), Data: i})
+			Snapshot()
+		}(i)
+	}
+	wg.Wait()
+	qs.Start(nil)
+
+	st := stats("prueba-cola")
+	if st.Probes != 10 || st.Successes != 5 || st.Failures["tls"] != 5 || st.Queued != 0 {
+		t.Errorf("stats = %+v", st)
+	}
+}
+
+func TestAddFunc(t *testing.T) {
+	Probe = "prueba-fuente"
+	var mu sync.Mutex
+	var seen []string
+	qs := NewQueueScanner(2, func(c *Ctx, a *QueueScannerScanParams) {
+		mu.Lock()
+		seen = append(seen, a.Name)
+		mu.Unlock()
+	})
+	qs.Add(&QueueScannerScanParams{Name: "lista"})
+	qs.AddFunc(3, func(add func(*QueueScannerScanParams)) {
+		for i := 0; i < 3; i++ {
+			add(&QueueScannerScanParams{Name: fmt.Sprint(i)})
+		}
+	})
+	if st := stats("prueba-fuente"); st.Queued != 4 {
+		t.Errorf("en cola = %d, se esperaban 4", st.Queued)
+	}
+	qs.Start(nil)
+
+	if len(seen) != 4 {
+		t.Errorf("escaneados = %v", seen)
+	}
+	if st := stats("prueba-fuente"); st.Probes != 4 || st.Queued != 0 {
+		t.Errorf("stats = %+v", st)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"strings"
 	"sync"
+	"time"
 
 	terminal "github.com/wayneashleyberry/terminal-dimensions"
 )
@@ -15,6 +16,7 @@ type Ctx struct {
 	ScanComplete    int
 
 	dataList []*QueueScannerScanParams
+	m        metrics
 
 	mx sync.Mutex
 	context.Context
@@ -29,11 +31,15 @@ func (c *Ctx) Logf(f string, a ...interface{}) {
 }
 
 func (c *Ctx) LogReplace(a ...string) {
+	c.mx.Lock()
 	scanSuccess := len(c.ScanSuccessList)
 	scanFailed := len(c.ScanFailedList)
-	scanCompletePercentage := float64(c.ScanComplete) / float64(len(c.dataList)) * 100
+	scanComplete := c.ScanComplete
+	total := c.m.total
+	c.mx.Unlock()
+	scanCompletePercentage := float64(scanComplete) / float64(total) * 100
 	s := fmt.Sprintf(
-		"  %.2f%% - C: %d / %d - S: %d - F: %d - %s", scanCompletePercentage, c.ScanComplete, len(c.dataList), scanSuccess, scanFailed, strings.Join(a, " "),
+		"  %.2f%% - C: %d / %d - S: %d - F: %d - %s", scanCompletePercentage, scanComplete, total, scanSuccess, scanFailed, strings.Join(a, " "),
 	)
 
 	termWidth, _, err := terminal.Dimensions()
@@ -60,9 +66,14 @@ func (c *Ctx) ScanSuccess(a interface{}, fn func()) {
 	}
 
 	c.ScanSuccessList = append(c.ScanSuccessList, a)
+	c.m.successes++
 }
 
 func (c *Ctx) ScanFailed(a interface{}, fn func()) {
+	c.scanFailed(a, FailRejected, fn)
+}
+
+func (c *Ctx) scanFailed(a interface{}, class string, fn func()) {
 	c.mx.Lock()
 	defer c.mx.Unlock()
 
@@ -71,6 +82,7 @@ func (c *Ctx) ScanFailed(a interface{}, fn func()) {
 	}
 
 	c.ScanFailedList = append(c.ScanFailedList, a)
+	c.m.failures[class]++
 }
 
 type QueueScannerScanParams struct {
@@ -84,6 +96,7 @@ type QueueScanner struct {
 	threads  int
 	scanFunc QueueScannerScanFunc
 	queue    chan *QueueScannerScanParams
+	sources  []func(add func(*QueueScannerScanParams))
 	wg       sync.WaitGroup
 
 	ctx *Ctx
@@ -96,6 +109,7 @@ func NewQueueScanner(threads int, scanFunc QueueScannerScanFunc) *QueueScanner {
 		queue:    make(chan *QueueScannerScanParams),
 		ctx:      &Ctx{},
 	}
+	register(t.ctx)
 
 	for i := 0; i < t.threads; i++ {
 		go t.run()
@@ -116,24 +130,43 @@ func (s *QueueScanner) run() {
 
 		s.ctx.LogReplace(a.Name)
 
+		s.ctx.begin()
+		start := time.Now()
 		s.scanFunc(s.ctx, a)
-
-		s.ctx.mx.Lock()
-		s.ctx.ScanComplete++
-		s.ctx.mx.Unlock()
+		s.ctx.end(time.Since(start))
 
 		s.ctx.LogReplace(a.Name)
 	}
 }
 
 func (s *QueueScanner) Add(dataList ...*QueueScannerScanParams) {
+	s.ctx.mx.Lock()
+	defer s.ctx.mx.Unlock()
+
 	s.ctx.dataList = append(s.ctx.dataList, dataList...)
+	s.ctx.m.total += len(dataList)
+}
+
+// AddFunc añade n elementos que genera source al empezar el escaneo, a
+// medida que los hilos los piden, sin guardarlos en memoria. source debe
+// llamar a add con cada uno.
+func (s *QueueScanner) AddFunc(n int, source func(add func(*QueueScannerScanParams))) {
+	s.ctx.mx.Lock()
+	defer s.ctx.mx.Unlock()
+
+	s.sources = append(s.sources, source)
+	s.ctx.m.total += n
 }
 
 func (s *QueueScanner) Start(doneFunc QueueScannerDoneFunc) {
 	for _, data := range s.ctx.dataList {
 		s.queue <- data
 	}
+	for _, source := range s.sources {
+		source(func(data *QueueScannerScanParams) {
+			s.queue <- data
+		})
+	}
 	close(s.queue)
 
 	s.wg.Wait()
@@ -0,0 +1,143 @@
+package resolve
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// Límites del tiempo de vida de las respuestas en caché.
+const (
+	negativeTTL = time.Minute // respuestas sin registros y sin SOA
+	maxTTL      = time.Hour
+)
+
+// Cache guarda las respuestas DNS durante su TTL. Las respuestas negativas
+// (NXDOMAIN o sin registros) se guardan el tiempo indicado por el SOA.
+type Cache struct {
+	mu      sync.Mutex
+	entries map[cacheKey]cacheEntry
+	hits    int
+	misses  int
+}
+
+// cacheKey identifica una pregunta DNS.
+type cacheKey struct {
+	name  string
+	qtype dnsmessage.Type
+	class dnsmessage.Class
+}
+
+type cacheEntry struct {
+	msg     []byte
+	expires time.Time
+}
+
+// NewCache devuelve una caché vacía.
+func NewCache() *Cache {
+	return &Cache{entries: make(map[cacheKey]cacheEntry)}
+}
+
+// questionKey devuelve la clave de la pregunta del mensaje.
+func questionKey(msg []byte) (cacheKey, bool) {
+	var p dnsmessage.Parser
+	if _, err := p.Start(msg); err != nil {
+		return cacheKey{}, false
+	}
+	q, err := p.Question()
+	if err != nil {
+		return cacheKey{}, false
+	}
+	return cacheKey{strings.ToLower(q.Name.String()), q.Type, q.Class}, true
+}
+
+// Get devuelve la respuesta guardada para la consulta, con su identificador.
+func (c *Cache) Get(query []byte) ([]byte, bool) {
+	key, ok := questionKey(query)
+	if !ok {
+		return nil, false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[key]
+	if !ok || time.Now().After(entry.expires) {
+		delete(c.entries, key)
+		c.misses++
+		return nil, false
+	}
+	c.hits++
+
+	msg := append([]byte(nil), entry.msg...)
+	msg[0], msg[1] = query[0], query[1]
+	return msg, true
+}
+
+// Put guarda la respuesta a la consulta si se puede cachear.
+func (c *Cache) Put(query, resp []byte) {
+	key, ok := questionKey(query)
+	if !ok {
+		return
+	}
+	ttl, ok := responseTTL(resp)
+	if !ok || ttl <= 0 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[key] = cacheEntry{
+		msg:     append([]byte(nil), resp...),
+		expires: time.Now().Add(ttl),
+	}
+}
+
+// Stats devuelve cuántas consultas se sirvieron de la caché y cuántas no.
+func (c *Cache) Stats() (hits, misses int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.hits, c.misses
+}
+
+// responseTTL devuelve cuánto tiempo se puede guardar la respuesta. Solo se
+// guardan las respuestas correctas y las NXDOMAIN.
+func responseTTL(resp []byte) (time.Duration, bool) {
+	var p dnsmessage.Parser
+	h, err := p.Start(resp)
+	if err != nil || h.Truncated {
+		return 0, false
+	}
+	if h.RCode != dnsmessage.RCodeSuccess && h.RCode != dnsmessage.RCodeNameError {
+		return 0, false
+	}
+	if err := p.SkipAllQuestions(); err != nil {
+		return 0, false
+	}
+
+	answers, err := p.AllAnswers()
+	if err != nil {
+		return 0, false
+	}
+	if len(answers) > 0 && h.RCode == dnsmessage.RCodeSuccess {
+		ttl := answers[0].Header.TTL
+		for _, rr := range answers[1:] {
+			ttl = min(ttl, rr.Header.TTL)
+		}
+		return min(time.Duration(ttl)*time.Second, maxTTL), true
+	}
+
+	// Respuesta negativa: TTL del SOA de la sección de autoridad (RFC 2308).
+	authorities, err := p.AllAuthorities()
+	if err != nil {
+		return 0, false
+	}
+	for _, rr := range authorities {
+		if soa, ok := rr.Body.(*dnsmessage.SOAResource); ok {
+			ttl := min(rr.Header.TTL, soa.MinTTL)
+			return min(time.Duration(ttl)*time.Second, maxTTL), true
+		}
+	}
+	return negativeTTL, true
+}
@@ -0,0 +1,124 @@
+package resolve
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"sync/atomic"
+	"time"
+)
+
+// defaultExchangeTimeout es el plazo de una consulta si el resolvedor de Go
+// no fija ninguno.
+const defaultExchangeTimeout = 5 * time.Second
+
+// Client reparte las consultas entre varios Upstream por turnos y guarda las
+// respuestas en una caché compartida.
+type Client struct {
+	Upstreams []Upstream
+	Cache     *Cache
+	next      atomic.Uint32
+}
+
+// NewClient devuelve un Client con una caché nueva.
+func NewClient(upstreams []Upstream) *Client {
+	return &Client{Upstreams: upstreams, Cache: NewCache()}
+}
+
+// Exchange responde la consulta desde la caché o la envía al siguiente
+// Upstream. Si falla se prueban los demás.
+func (c *Client) Exchange(ctx context.Context, query []byte) ([]byte, error) {
+	if resp, ok := c.Cache.Get(query); ok {
+		return resp, nil
+	}
+	if len(c.Upstreams) == 0 {
+		return nil, errors.New("no hay resolvedores configurados")
+	}
+
+	start := int(c.next.Add(1) - 1)
+	var err error
+	for i := range c.Upstreams {
+		upstream := c.Upstreams[(start+i)%len(c.Upstreams)]
+		var resp []byte
+		resp, err = upstream.Exchange(ctx, query)
+		if err == nil {
+			c.Cache.Put(query, resp)
+			return resp, nil
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return nil, err
+}
+
+// Resolver devuelve un net.Resolver que envía todas sus consultas a través
+// del Client.
+func (c *Client) Resolver() *net.Resolver {
+	return &net.Resolver{
+		PreferGo: true,
+		Dial: func(context.Context, string, string) (net.Conn, error) {
+			return &streamConn{client: c}, nil
+		},
+	}
+}
+
+// streamConn es la conexión que ve el resolvedor de Go: recibe consultas
+// con el formato de DNS sobre TCP (longitud de 2 bytes y mensaje) y
+// devuelve las respuestas del Client con el mismo formato.
+type streamConn struct {
+	client   *Client
+	deadline time.Time
+	in       bytes.Buffer
+	out      bytes.Buffer
+}
+
+func (c *streamConn) Write(b []byte) (int, error) {
+	c.in.Write(b)
+	for c.in.Len() >= 2 {
+		n := int(binary.BigEndian.Uint16(c.in.Bytes()))
+		if c.in.Len() < 2+n {
+			break
+		}
+		c.in.Next(2)
+		query := append([]byte(nil), c.in.Next(n)...)
+
+		resp, err := c.exchange(query)
+		if err != nil {
+			return 0, err
+		}
+		var length [2]byte
+		binary.BigEndian.PutUint16(length[:], uint16(len(resp)))
+		c.out.Write(length[:])
+		c.out.Write(resp)
+	}
+	return len(b), nil
+}
+
+// exchange envía la consulta con el plazo fijado por el resolvedor de Go.
+func (c *streamConn) exchange(query []byte) ([]byte, error) {
+	deadline := c.deadline
+	if deadline.IsZero() {
+		deadline = time.Now().Add(defaultExchangeTimeout)
+	}
+	ctx, cancel := context.WithDeadline(context.Background(), deadline)
+	defer cancel()
+	return c.client.Exchange(ctx, query)
+}
+
+func (c *streamConn) Read(b []byte) (int, error) {
+	if c.out.Len() == 0 {
+		return 0, io.EOF
+	}
+	return c.out.Read(b)
+}
+
+func (c *streamConn) Close() error                       { return nil }
+func (c *streamConn) LocalAddr() net.Addr                { return &net.TCPAddr{} }
+func (c *streamConn) RemoteAddr() net.Addr               { return &net.TCPAddr{} }
+func (c *streamConn) SetDeadline(t time.Time) error      { c.deadline = t; return nil }
+func (c *streamConn) SetReadDeadline(t time.Time) error  { return nil }
+func (c *streamConn) SetWriteDeadline(t time.Time) error { c.deadline = t; return nil }
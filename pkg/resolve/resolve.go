@@ -0,0 +1,114 @@
+// Package resolve resuelve dominios a sus direcciones A/AAAA y su CNAME,
+// clasifica los fallos de resolución y permite consultar servidores DNS por
+// UDP, TCP, TLS o HTTPS con una caché compartida.
+package resolve
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"time"
+)
+
+// Status es el resultado de una resolución.
+type Status string
+
+// Estados de una resolución.
+const (
+	OK       Status = "ok"
+	NXDomain Status = "nxdomain" // el dominio no existe o no tiene registros
+	ServFail Status = "servfail" // el servidor no pudo responder
+	Timeout  Status = "timeout"
+	Error    Status = "error"
+)
+
+// Statuses son los estados en el orden en que se muestran.
+var Statuses = []Status{OK, NXDomain, ServFail, Timeout, Error}
+
+// Result es la resolución de un dominio.
+type Result struct {
+	Domain string
+	CNAME  string // nombre canónico si difiere del dominio
+	IPs    []net.IP
+	Status Status
+	Err    error
+}
+
+// Resolver resuelve dominios con el resolvedor del sistema.
+type Resolver struct {
+	Resolver *net.Resolver
+	Timeout  time.Duration
+}
+
+// New devuelve un Resolver con el resolvedor del sistema.
+func New(timeout time.Duration) *Resolver {
+	return &Resolver{Resolver: net.DefaultResolver, Timeout: timeout}
+}
+
+// Resolve devuelve las direcciones A y AAAA de domain y su CNAME.
+func (r *Resolver) Resolve(ctx context.Context, domain string) *Result {
+	res := &Result{Domain: domain}
+
+	ctx, cancel := context.WithTimeout(ctx, r.Timeout)
+	defer cancel()
+
+	ips, err := r.Resolver.LookupIP(ctx, "ip", domain)
+	if err != nil {
+		res.Status, res.Err = Classify(err), err
+		return res
+	}
+	res.IPs = ips
+	res.Status = OK
+
+	if cname, err := r.Resolver.LookupCNAME(ctx, domain); err == nil {
+		cname = strings.TrimSuffix(cname, ".")
+		if !strings.EqualFold(cname, strings.TrimSuffix(domain, ".")) {
+			res.CNAME = cname
+		}
+	}
+	return res
+}
+
+// Classify distingue NXDOMAIN, SERVFAIL y los tiempos de espera en un error
+// de resolución.
+func Classify(err error) Status {
+	if err == nil {
+		return OK
+	}
+	var dnsErr *net.DNSError
+	if !errors.As(err, &dnsErr) {
+		if errors.Is(err, context.DeadlineExceeded) {
+			return Timeout
+		}
+		return Error
+	}
+	switch {
+	case dnsErr.IsTimeout:
+		return Timeout
+	case dnsErr.IsNotFound:
+		return NXDomain
+	case strings.Contains(dnsErr.Err, "server misbehaving"):
+		return ServFail
+	}
+	return Error
+}
+
+// IsDomain indica si target es un nombre de host y no una dirección IP o un
+// rango.
+func IsDomain(target string) bool {
+	if target == "" || net.ParseIP(target) != nil {
+		return false
+	}
+	letter := false
+	for _, c := range target {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z':
+			letter = true
+		case c >= '0' && c <= '9', c == '-', c == '.', c == '_':
+		default:
+			return false
+		}
+	}
+	return letter
+}
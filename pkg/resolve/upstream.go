@@ -0,0 +1,208 @@
+package resolve
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// dnsMaxSize es el tamaño máximo de un mensaje DNS.
+const dnsMaxSize = 65535
+
+// Upstream envía una consulta DNS (mensaje en formato de red) a un servidor
+// y devuelve su respuesta.
+type Upstream interface {
+	Exchange(ctx context.Context, query []byte) ([]byte, error)
+	String() string
+}
+
+// Bootstrap es el Dialer con el que se llega a los servidores DNS. Resuelve
+// los nombres de los servidores DoT y DoH con el resolvedor del sistema, que
+// no depende de los Upstream configurados.
+var Bootstrap = &net.Dialer{Resolver: &net.Resolver{}}
+
+// ParseUpstream interpreta la dirección de un servidor DNS:
+//
+//	1.1.1.1, 1.1.1.1:53, udp://1.1.1.1   UDP (TCP si la respuesta llega truncada)
+//	tcp://1.1.1.1                        TCP
+//	tls://1.1.1.1, tls://dns.google      DNS sobre TLS (puerto 853)
+//	https://dns.google/dns-query         DNS sobre HTTPS (RFC 8484)
+func ParseUpstream(s string) (Upstream, error) {
+	if ip := net.ParseIP(s); ip != nil {
+		return &udpUpstream{addr: net.JoinHostPort(s, "53")}, nil
+	}
+	if !strings.Contains(s, "://") {
+		s = "udp://" + s
+	}
+	u, err := url.Parse(s)
+	if err != nil {
+		return nil, fmt.Errorf("resolvedor inválido: %w", err)
+	}
+	if u.Hostname() == "" {
+		return nil, fmt.Errorf("resolvedor sin servidor: %s", s)
+	}
+
+	switch u.Scheme {
+	case "udp":
+		return &udpUpstream{addr: hostPort(u, "53")}, nil
+	case "tcp":
+		return &streamUpstream{addr: hostPort(u, "53")}, nil
+	case "tls":
+		return &streamUpstream{
+			addr: hostPort(u, "853"),
+			tls:  &tls.Config{ServerName: u.Hostname()},
+		}, nil
+	case "https":
+		return &dohUpstream{
+			url: u.String(),
+			client: &http.Client{Transport: &http.Transport{
+				DialContext:       Bootstrap.DialContext,
+				ForceAttemptHTTP2: true,
+			}},
+		}, nil
+	}
+	return nil, fmt.Errorf("esquema de resolvedor no soportado: %s", u.Scheme)
+}
+
+// hostPort devuelve host:puerto de la URL con el puerto por defecto indicado.
+func hostPort(u *url.URL, port string) string {
+	if u.Port() != "" {
+		port = u.Port()
+	}
+	return net.JoinHostPort(u.Hostname(), port)
+}
+
+// setDeadline aplica a conn el plazo de ctx.
+func setDeadline(ctx context.Context, conn net.Conn) {
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+}
+
+// udpUpstream consulta por UDP y repite por TCP las respuestas truncadas.
+type udpUpstream struct {
+	addr string
+}
+
+func (u *udpUpstream) String() string {
+	return "udp://" + u.addr
+}
+
+func (u *udpUpstream) Exchange(ctx context.Context, query []byte) ([]byte, error) {
+	conn, err := Bootstrap.DialContext(ctx, "udp", u.addr)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	setDeadline(ctx, conn)
+
+	if _, err := conn.Write(query); err != nil {
+		return nil, err
+	}
+	buf := make([]byte, dnsMaxSize)
+	for {
+		n, err := conn.Read(buf)
+		if err != nil {
+			return nil, err
+		}
+		// Se descartan las respuestas con otro identificador.
+		if n < 12 || buf[0] != query[0] || buf[1] != query[1] {
+			continue
+		}
+		if buf[2]&0x02 != 0 { // TC: respuesta truncada
+			return (&streamUpstream{addr: u.addr}).Exchange(ctx, query)
+		}
+		return append([]byte(nil), buf[:n]...), nil
+	}
+}
+
+// streamUpstream consulta por TCP o, con tls, por DNS sobre TLS.
+type streamUpstream struct {
+	addr string
+	tls  *tls.Config
+}
+
+func (u *streamUpstream) String() string {
+	if u.tls != nil {
+		return "tls://" + u.addr
+	}
+	return "tcp://" + u.addr
+}
+
+func (u *streamUpstream) Exchange(ctx context.Context, query []byte) ([]byte, error) {
+	var conn net.Conn
+	var err error
+	if u.tls != nil {
+		conn, err = (&tls.Dialer{NetDialer: Bootstrap, Config: u.tls}).DialContext(ctx, "tcp", u.addr)
+	} else {
+		conn, err = Bootstrap.DialContext(ctx, "tcp", u.addr)
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	setDeadline(ctx, conn)
+
+	msg := make([]byte, 2+len(query))
+	binary.BigEndian.PutUint16(msg, uint16(len(query)))
+	copy(msg[2:], query)
+	if _, err := conn.Write(msg); err != nil {
+		return nil, err
+	}
+
+	var length [2]byte
+	if _, err := io.ReadFull(conn, length[:]); err != nil {
+		return nil, err
+	}
+	resp := make([]byte, binary.BigEndian.Uint16(length[:]))
+	if _, err := io.ReadFull(conn, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// dohUpstream consulta por DNS sobre HTTPS con POST.
+type dohUpstream struct {
+	url    string
+	client *http.Client
+}
+
+func (u *dohUpstream) String() string {
+	return u.url
+}
+
+func (u *dohUpstream) Exchange(ctx context.Context, query []byte) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u.url, bytes.NewReader(query))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/dns-message")
+	req.Header.Set("Accept", "application/dns-message")
+
+	resp, err := u.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s respondió %s", u.url, resp.Status)
+	}
+
+	msg, err := io.ReadAll(io.LimitReader(resp.Body, dnsMaxSize))
+	if err != nil {
+		return nil, err
+	}
+	if len(msg) < 12 {
+		return nil, fmt.Errorf("%s devolvió una respuesta DNS inválida", u.url)
+	}
+	// El servidor puede responder con otro identificador (RFC 8484 usa 0).
+	msg[0], msg[1] = query[0], query[1]
+	return msg, nil
+}